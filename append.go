@@ -0,0 +1,53 @@
+// Copyright 2023-now by lifenjoiner. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE file.
+
+package iprefix
+
+import (
+	"net/netip"
+	"slices"
+)
+
+// AppendPrefixPatterns appends the string IP prefix patterns of a parsed
+// prefix to `dst` and returns the extended slice.
+func AppendPrefixPatterns(dst []string, p netip.Prefix) []string {
+	dst = slices.Grow(dst, PatternCount(p))
+	processPrefix(p, IPv6All, func(s string) bool {
+		dst = append(dst, s)
+		return true
+	})
+	return dst
+}
+
+// AppendCIDRPatterns appends the string IP prefix patterns of CIDR to `dst`
+// and returns the extended slice, or `dst` and the parsing error.
+func AppendCIDRPatterns(dst []string, s string) ([]string, error) {
+	p, err := parsePrefix(s)
+	if err != nil {
+		return dst, err
+	}
+	return AppendPrefixPatterns(dst, p), nil
+}
+
+// AppendPrefixText appends the IP prefix patterns of a parsed prefix to `dst`,
+// each ended by a newline, and returns the extended buffer. It allocates
+// nothing for IPv4 once `dst` has the room.
+func AppendPrefixText(dst []byte, p netip.Prefix) []byte {
+	genPrefix(p, IPv6All, func(b []byte) bool {
+		dst = append(dst, b...)
+		dst = append(dst, '\n')
+		return true
+	})
+	return dst
+}
+
+// AppendCIDRText appends the IP prefix patterns of CIDR to `dst`, each ended by
+// a newline, and returns the extended buffer, or `dst` and the parsing error.
+func AppendCIDRText(dst []byte, s string) ([]byte, error) {
+	p, err := parsePrefix(s)
+	if err != nil {
+		return dst, err
+	}
+	return AppendPrefixText(dst, p), nil
+}
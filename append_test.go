@@ -0,0 +1,57 @@
+// Copyright 2023-now by lifenjoiner. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE file.
+
+package iprefix
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestAppendCIDR(t *testing.T) {
+	prepareExpected()
+	for _, mt := range test {
+		if len(mt.CIDR) == 0 {
+			continue
+		}
+		head := []string{"x"}
+		ps, err := AppendCIDRPatterns(head, mt.CIDR)
+		b, errText := AppendCIDRText([]byte("x\n"), mt.CIDR)
+		switch d := mt.Expected.(type) {
+		case []string:
+			if errText != nil || string(b) != strings.Join(ps, "\n")+"\n" {
+				t.Error(mt.CIDR, string(b), errText)
+			}
+			if err != nil || ps[0] != "x" || !validate(ps[1:], d) {
+				t.Error(mt.CIDR, ps, err)
+			}
+		case error:
+			if err == nil || !slices.Equal(ps, head) || errText == nil || string(b) != "x\n" {
+				t.Error(mt.CIDR)
+			}
+		}
+	}
+}
+
+func TestAppendCIDRTextAllocs(t *testing.T) {
+	dst := make([]byte, 0, 4096)
+	for _, s := range []string{"10.0.0.0/24", "10.0.0.0/20", "::ffff:10.0.0.0/120", "2001:db8::1/128"} {
+		// warm up the pools
+		AppendCIDRText(dst, s)
+		if n := testing.AllocsPerRun(100, func() {
+			AppendCIDRText(dst[:0], s)
+		}); n != 0 {
+			t.Error(s, n)
+		}
+	}
+}
+
+func BenchmarkAppendCIDRText(b *testing.B) {
+	b.ReportAllocs()
+	var dst []byte
+	for range b.N {
+		dst, _ = AppendCIDRText(dst[:0], "10.0.0.0/25")
+	}
+}
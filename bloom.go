@@ -0,0 +1,115 @@
+// Copyright 2023-now by lifenjoiner. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE file.
+
+package iprefix
+
+import (
+	"encoding/binary"
+	"math"
+	"math/bits"
+	"net/netip"
+)
+
+// bloomFilter tells fast that an IP is in none of the prefixes, by looking up
+// its masked address of every prefix length of the family.
+type bloomFilter struct {
+	bits []uint64
+	mask uint64
+	k    int
+	// lens are the prefix lengths of IPv4 and IPv6.
+	lens [2][]int
+}
+
+func newBloomFilter(ps []netip.Prefix, bitsPerPrefix int) *bloomFilter {
+	m := uint64(len(ps) * bitsPerPrefix)
+	if m < 64 {
+		m = 64
+	}
+	m = 1 << bits.Len64(m-1)
+	k := int(math.Round(float64(bitsPerPrefix) * math.Ln2))
+	k = min(max(k, 1), 16)
+	f := &bloomFilter{bits: make([]uint64, m/64), mask: m - 1, k: k}
+	var seen [2][129]bool
+	for _, p := range ps {
+		family := bloomFamily(p.Addr())
+		if !seen[family][p.Bits()] {
+			seen[family][p.Bits()] = true
+			f.lens[family] = append(f.lens[family], p.Bits())
+		}
+		hi, lo := halves(p.Addr())
+		f.add(bloomHash(hi, lo, bits128(p.Addr(), p.Bits())))
+	}
+	return f
+}
+
+func bloomFamily(addr netip.Addr) int {
+	if addr.Is4() {
+		return 0
+	}
+	return 1
+}
+
+// bloomHash returns 2 hashes of the address masked to `n` bits, of the 128
+// bits As16 halves `hi` and `lo`.
+func bloomHash(hi, lo uint64, n int) (h1, h2 uint64) {
+	if n <= 64 {
+		hi &= ^(^uint64(0) >> n)
+		lo = 0
+	} else {
+		lo &= ^(^uint64(0) >> (n - 64))
+	}
+	h := mix64(hi ^ mix64(lo^uint64(n)))
+	return h, h>>32 | 1
+}
+
+// mix64 is the finalizer of SplitMix64.
+func mix64(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	return x ^ x>>31
+}
+
+// halves returns the 128 bits of an address as 2 uint64.
+func halves(addr netip.Addr) (hi, lo uint64) {
+	ip := addr.As16()
+	return binary.BigEndian.Uint64(ip[:8]), binary.BigEndian.Uint64(ip[8:])
+}
+
+// bits128 returns the prefix length `n` in the 128 bits of As16.
+func bits128(addr netip.Addr, n int) int {
+	if addr.Is4() {
+		return 96 + n
+	}
+	return n
+}
+
+func (f *bloomFilter) add(h1, h2 uint64) {
+	for i := 0; i < f.k; i++ {
+		x := (h1 + uint64(i)*h2) & f.mask
+		f.bits[x/64] |= 1 << (x % 64)
+	}
+}
+
+func (f *bloomFilter) test(h1, h2 uint64) bool {
+	for i := 0; i < f.k; i++ {
+		x := (h1 + uint64(i)*h2) & f.mask
+		if f.bits[x/64]&(1<<(x%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// mayContain reports false if `addr` is surely in none of the prefixes.
+func (f *bloomFilter) mayContain(addr netip.Addr) bool {
+	hi, lo := halves(addr)
+	for _, l := range f.lens[bloomFamily(addr)] {
+		if f.test(bloomHash(hi, lo, bits128(addr, l))) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,74 @@
+// Copyright 2023-now by lifenjoiner. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE file.
+
+package iprefix
+
+import "net/netip"
+
+// Builder collects CIDRs, IP ranges and IPs from many sources, merging them
+// only once for the patterns. The zero value is an empty Builder.
+type Builder struct {
+	ranges []addrRange
+}
+
+// Add adds a pattern, a CIDR, an IP range `IP1-IP2` or an IP.
+func (b *Builder) Add(s string) error {
+	r, err := parseEntry(s)
+	if err != nil {
+		return err
+	}
+	b.ranges = append(b.ranges, r)
+	return nil
+}
+
+// AddCIDR adds CIDR.
+func (b *Builder) AddCIDR(s string) error {
+	p, err := parsePrefix(s)
+	if err != nil {
+		return err
+	}
+	b.AddPrefix(p)
+	return nil
+}
+
+// AddPrefix adds a parsed prefix.
+func (b *Builder) AddPrefix(p netip.Prefix) {
+	if p.IsValid() {
+		b.ranges = append(b.ranges, prefixRange(p))
+	}
+}
+
+// AddRange adds IP range.
+// `s` is start IP. `e` is end IP.
+func (b *Builder) AddRange(s, e string) error {
+	addr1, addr2, err := parseRange(s, e)
+	if err != nil {
+		return err
+	}
+	b.ranges = append(b.ranges, addrRange{addr1, addr2})
+	return nil
+}
+
+// AddAddr adds a parsed IP.
+func (b *Builder) AddAddr(addr netip.Addr) error {
+	if err := checkRange(addr, addr); err != nil {
+		return err
+	}
+	b.ranges = append(b.ranges, addrRange{addr, addr})
+	return nil
+}
+
+// Patterns generates the least string IP prefix pattern of all the added,
+// tuned by `opts`.
+func (b *Builder) Patterns(opts ...Option) ([]string, error) {
+	b.ranges = mergeRanges(b.ranges)
+	o := newOptions(append(opts, Minimize()))
+	return o.output(o.rangesGenerator(b.ranges))
+}
+
+// Set returns the PatternSet of all the added.
+func (b *Builder) Set() *PatternSet {
+	b.ranges = mergeRanges(b.ranges)
+	return &PatternSet{append([]addrRange(nil), b.ranges...)}
+}
@@ -0,0 +1,53 @@
+// Copyright 2023-now by lifenjoiner. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE file.
+
+package iprefix
+
+import (
+	"net/netip"
+	"testing"
+)
+
+func TestBuilder(t *testing.T) {
+	var b Builder
+	if err := b.AddCIDR("10.0.0.0/24"); err != nil {
+		t.Error(err)
+	}
+	if err := b.AddRange("10.0.0.128", "10.0.1.255"); err != nil {
+		t.Error(err)
+	}
+	if err := b.AddAddr(netip.MustParseAddr("10.0.2.0")); err != nil {
+		t.Error(err)
+	}
+	b.AddPrefix(netip.MustParsePrefix("10.0.2.0/31"))
+	if err := b.Add("10.0.2.2-10.0.2.255"); err != nil {
+		t.Error(err)
+	}
+	if err := b.Add("::1"); err != nil {
+		t.Error(err)
+	}
+	ps, err := b.Patterns()
+	if err != nil || !validate(ps, []string{"10.0.0.*", "10.0.1.*", "10.0.2.*", "::1"}) {
+		t.Error(ps, err)
+	}
+	if ps, err = b.Patterns(Limit(1)); err != nil || !validate(ps, []string{"10.0.0.*"}) {
+		t.Error(ps, err)
+	}
+	if set := b.Set(); set.Count().Int64() != 769 {
+		t.Error(set.Count())
+	}
+
+	if err = b.AddCIDR("10.0.0.1"); err == nil {
+		t.Error("10.0.0.1")
+	}
+	if err = b.AddRange("10.0.0.2", "10.0.0.1"); err == nil {
+		t.Error("10.0.0.2-10.0.0.1")
+	}
+	if err = b.AddAddr(netip.Addr{}); err == nil {
+		t.Error("zero Addr")
+	}
+	if err = b.Add("x"); err == nil {
+		t.Error("x")
+	}
+}
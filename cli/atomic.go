@@ -0,0 +1,84 @@
+// Copyright 2023-now by lifenjoiner. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE file.
+
+package main
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// atomicFile is written as a temporary file beside the target, and renamed
+// to it on Commit, so the target is either the old or the whole new one.
+type atomicFile struct {
+	*os.File
+	path string
+	done bool
+}
+
+func createAtomic(path string) (*atomicFile, error) {
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return nil, err
+	}
+	// keep the mode of the target
+	mode := os.FileMode(0o644)
+	if fi, err := os.Stat(path); err == nil {
+		mode = fi.Mode().Perm()
+	}
+	if err = f.Chmod(mode); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return nil, err
+	}
+	return &atomicFile{File: f, path: path}, nil
+}
+
+// Commit syncs the temporary file and replaces the target with it.
+func (af *atomicFile) Commit() error {
+	if err := af.Sync(); err != nil {
+		return err
+	}
+	if err := af.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(af.Name(), af.path); err != nil {
+		return err
+	}
+	af.done = true
+	return nil
+}
+
+// Abort removes the temporary file if not committed.
+func (af *atomicFile) Abort() {
+	if af.done {
+		return
+	}
+	af.Close()
+	os.Remove(af.Name())
+	af.done = true
+}
+
+// backup links or copies the file of `path` to `bak`, replacing it.
+func backup(path, bak string) error {
+	os.Remove(bak)
+	if os.Link(path, bak) == nil {
+		return nil
+	}
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	af, err := createAtomic(bak)
+	if err != nil {
+		return err
+	}
+	defer af.Abort()
+	if _, err = io.Copy(af, src); err != nil {
+		return err
+	}
+	return af.Commit()
+}
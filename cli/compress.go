@@ -0,0 +1,55 @@
+// Copyright 2023-now by lifenjoiner. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"os"
+)
+
+// magics are the leading bytes of the compressed inputs.
+var magics = []struct {
+	name  string
+	magic []byte
+}{
+	{"gzip", []byte{0x1f, 0x8b}},
+	{"zstd", []byte{0x28, 0xb5, 0x2f, 0xfd}},
+}
+
+// compression returns the compression of `br` by its magic bytes, or "".
+func compression(br *bufio.Reader) string {
+	for _, m := range magics {
+		if b, _ := br.Peek(len(m.magic)); bytes.Equal(b, m.magic) {
+			return m.name
+		}
+	}
+	return ""
+}
+
+// decompress returns `r` decompressed on the fly if it is compressed.
+func decompress(r io.Reader) (io.Reader, error) {
+	br := bufio.NewReader(r)
+	switch compression(br) {
+	case "gzip":
+		return gzip.NewReader(br)
+	case "zstd":
+		return nil, errors.New("zstd is unsupported, decompress it first")
+	}
+	return br, nil
+}
+
+// isCompressed reports whether the file of `path` is compressed.
+func isCompressed(path string) (bool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+	return compression(bufio.NewReader(f)) != "", nil
+}
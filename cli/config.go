@@ -0,0 +1,299 @@
+// Copyright 2023-now by lifenjoiner. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE file.
+
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// job is a run of expand configured by a section of a config file.
+type job struct {
+	name string
+	args []string
+	// every is the interval of its schedule, 0 to run once
+	every time.Duration
+}
+
+// configKeys maps the readable keys to the flags of expand, the others are the
+// flag names themselves.
+var configKeys = map[string]string{
+	"input":   "f",
+	"output":  "o",
+	"exclude": "x",
+	"comment": "c",
+	"jobs":    "j",
+	"inplace": "i",
+}
+
+// pathFlags are the flags taking paths, relative to the config file.
+var pathFlags = []string{"f", "o", "x"}
+
+// parseConfig parses a config file of a TOML subset: sections of jobs, and
+// `key = value` lines of strings, booleans, integers or one-line arrays of
+// strings. The keys before the first section are the defaults of the jobs, or
+// the only job if there is no section.
+func parseConfig(path string) ([]job, error) {
+	dir := filepath.Dir(path)
+	var defaults job
+	var jobs []job
+	cur := &defaults
+	n := 0
+	err := scanFile(path, 64*1024, func(line string) error {
+		n++
+		s := strings.TrimSpace(line)
+		if len(s) == 0 || s[0] == '#' {
+			return nil
+		}
+		if s[0] == '[' {
+			end := strings.IndexByte(s, ']')
+			if end < 0 || !isComment(s[end+1:]) {
+				return fmt.Errorf("%s:%d: bad section: %s", path, n, s)
+			}
+			name := strings.TrimSpace(s[1:end])
+			if slices.ContainsFunc(jobs, func(j job) bool { return j.name == name }) {
+				return fmt.Errorf("%s:%d: duplicate job: %s", path, n, name)
+			}
+			jobs = append(jobs, job{name, slices.Clone(defaults.args), defaults.every})
+			cur = &jobs[len(jobs)-1]
+			return nil
+		}
+		key, value, ok := strings.Cut(s, "=")
+		if !ok {
+			return fmt.Errorf("%s:%d: not a key = value line: %s", path, n, s)
+		}
+		key = strings.TrimSpace(key)
+		vs, err := parseValue(value)
+		if err != nil {
+			return fmt.Errorf("%s:%d: %s: %w", path, n, key, err)
+		}
+		if key == "schedule" {
+			if cur.every, err = parseSchedule(vs); err != nil {
+				return fmt.Errorf("%s:%d: %s: %w", path, n, key, err)
+			}
+			return nil
+		}
+		name := key
+		if f, ok := configKeys[key]; ok {
+			name = f
+		}
+		for _, v := range vs {
+			if slices.Contains(pathFlags, name) && v != "-" && !filepath.IsAbs(v) {
+				v = filepath.Join(dir, v)
+			}
+			cur.args = append(cur.args, "-"+name+"="+v)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(jobs) == 0 {
+		defaults.name = filepath.Base(path)
+		jobs = append(jobs, defaults)
+	}
+	return jobs, nil
+}
+
+// parseSchedule parses the value of a schedule, the interval of the runs, like
+// "24h" or "90m".
+func parseSchedule(vs []string) (time.Duration, error) {
+	if len(vs) != 1 {
+		return 0, errors.New("not one interval")
+	}
+	d, err := time.ParseDuration(vs[0])
+	if err != nil {
+		return 0, err
+	}
+	if d < time.Minute {
+		return 0, fmt.Errorf("interval under 1m: %s", vs[0])
+	}
+	return d, nil
+}
+
+// nextRun returns the first time after `now` of the runs every `every` from
+// `last`, skipping the missed ones.
+func nextRun(last, now time.Time, every time.Duration) time.Time {
+	next := last.Add(every)
+	if next.After(now) {
+		return next
+	}
+	return next.Add(now.Sub(next).Truncate(every) + every)
+}
+
+// isComment reports whether `s` is blank or a comment.
+func isComment(s string) bool {
+	s = strings.TrimSpace(s)
+	return len(s) == 0 || s[0] == '#'
+}
+
+// parseValue parses a value, a scalar or an array of them, followed by an
+// optional comment.
+func parseValue(s string) (vs []string, err error) {
+	s = strings.TrimSpace(s)
+	rest, ok := strings.CutPrefix(s, "[")
+	if !ok {
+		v, tail, err := parseScalar(s)
+		if err != nil {
+			return nil, err
+		}
+		if !isComment(tail) {
+			return nil, fmt.Errorf("trailing: %s", tail)
+		}
+		return []string{v}, nil
+	}
+	for {
+		rest = strings.TrimSpace(rest)
+		if tail, ok := strings.CutPrefix(rest, "]"); ok {
+			if !isComment(tail) {
+				return nil, fmt.Errorf("trailing: %s", tail)
+			}
+			return vs, nil
+		}
+		var v string
+		if v, rest, err = parseScalar(rest); err != nil {
+			return nil, err
+		}
+		vs = append(vs, v)
+		rest = strings.TrimSpace(rest)
+		if tail, ok := strings.CutPrefix(rest, ","); ok {
+			rest = tail
+		} else if !strings.HasPrefix(rest, "]") {
+			return nil, errors.New("unterminated array")
+		}
+	}
+}
+
+// parseScalar parses the leading string, boolean or integer of `s`.
+func parseScalar(s string) (v, tail string, err error) {
+	switch {
+	case strings.HasPrefix(s, `"`):
+		for i := 1; i < len(s); i++ {
+			switch s[i] {
+			case '\\':
+				i++
+			case '"':
+				v, err = strconv.Unquote(s[:i+1])
+				return v, s[i+1:], err
+			}
+		}
+		return "", "", errors.New("unterminated string")
+	case strings.HasPrefix(s, "'"):
+		end := strings.IndexByte(s[1:], '\'')
+		if end < 0 {
+			return "", "", errors.New("unterminated string")
+		}
+		return s[1 : end+1], s[end+2:], nil
+	}
+	end := strings.IndexAny(s, " \t,]#")
+	if end < 0 {
+		end = len(s)
+	}
+	v, tail = s[:end], s[end:]
+	if v != "true" && v != "false" {
+		if _, err = strconv.Atoi(v); err != nil {
+			return "", "", fmt.Errorf("not a string, boolean or integer: %s", v)
+		}
+	}
+	return v, tail, nil
+}
+
+func runRun(prog string, args []string) int {
+	var names files
+	var once bool
+
+	fs := flag.NewFlagSet(prog, flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s [-job name]... config\n"+
+			"Runs the jobs of the config file in order, each as expand with the flags of\n"+
+			"its keys, like:\n\n"+
+			"    format = \"cidr\"        # defaults of the jobs\n"+
+			"    [blocklist]\n"+
+			"    input = [\"a.txt\", \"b.txt\"]\n"+
+			"    exclude = [\"ours.txt\"]\n"+
+			"    output = \"blocklist.txt\"\n"+
+			"    sort = true\n"+
+			"    schedule = \"24h\"      # runs again every 24 hours\n\n"+
+			"input, output, exclude, comment, jobs and inplace stand for -f, -o, -x, -c, -j\n"+
+			"and -i, the other keys are the flag names. The paths are relative to the\n"+
+			"config file. The scheduled jobs keep it running until killed, unless -once.\n", prog)
+		fs.PrintDefaults()
+	}
+	fs.Var(&names, "job", "run only the job of the `name`, repeatable")
+	fs.BoolVar(&once, "once", false, "run the scheduled jobs only once")
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return 1
+	}
+	jobs, err := parseConfig(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return 1
+	}
+	for _, name := range names {
+		if !slices.ContainsFunc(jobs, func(j job) bool { return j.name == name }) {
+			fmt.Fprintf(os.Stderr, "no job: %s\n", name)
+			return 1
+		}
+	}
+	// all the jobs checked before running any
+	var picked []job
+	var xs []*expansion
+	for _, j := range jobs {
+		if len(names) > 0 && !slices.Contains(names, j.name) {
+			continue
+		}
+		x, err := parseExpand(prog+" "+j.name, j.args, flag.ContinueOnError)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "job %s: %v\n", j.name, err)
+			return 1
+		}
+		picked, xs = append(picked, j), append(xs, x)
+	}
+	ret := 0
+	next := make([]time.Time, len(xs))
+	for i, x := range xs {
+		start := time.Now()
+		if x.run() != 0 {
+			fmt.Fprintf(os.Stderr, "job %s failed\n", picked[i].name)
+			ret = 1
+		}
+		if picked[i].every > 0 {
+			next[i] = nextRun(start, time.Now(), picked[i].every)
+		}
+	}
+	if once || !slices.ContainsFunc(picked, func(j job) bool { return j.every > 0 }) {
+		return ret
+	}
+	for {
+		i := -1
+		for k, t := range next {
+			if !t.IsZero() && (i < 0 || t.Before(next[i])) {
+				i = k
+			}
+		}
+		j := picked[i]
+		time.Sleep(time.Until(next[i]))
+		start := time.Now()
+		// parsed again for a clean run, checked at first
+		x, err := parseExpand(prog+" "+j.name, j.args, flag.ContinueOnError)
+		switch {
+		case err != nil:
+			fmt.Fprintf(os.Stderr, "job %s: %v\n", j.name, err)
+		case x.run() != 0:
+			fmt.Fprintf(os.Stderr, "job %s failed\n", j.name)
+		}
+		next[i] = nextRun(start, time.Now(), j.every)
+	}
+}
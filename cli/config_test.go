@@ -0,0 +1,137 @@
+// Copyright 2023-now by lifenjoiner. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE file.
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestParseScalar(t *testing.T) {
+	tests := []struct {
+		s        string
+		v, tail  string
+		hasError bool
+	}{
+		{`"a b" # c`, "a b", " # c", false},
+		{`"a\"b",`, `a"b`, ",", false},
+		{`"a\tb"`, "a\tb", "", false},
+		{`'a\tb'`, `a\tb`, "", false},
+		{"true", "true", "", false},
+		{"false]", "false", "]", false},
+		{"12 # c", "12", " # c", false},
+		{"-1,", "-1", ",", false},
+		{`"a`, "", "", true},
+		{`'a`, "", "", true},
+		{"yes", "", "", true},
+		{"1.5", "", "", true},
+		{"", "", "", true},
+	}
+	for _, mt := range tests {
+		v, tail, err := parseScalar(mt.s)
+		if (err != nil) != mt.hasError || v != mt.v || tail != mt.tail {
+			t.Errorf("%q: %q %q %v", mt.s, v, tail, err)
+		}
+	}
+}
+
+func TestParseValue(t *testing.T) {
+	tests := []struct {
+		s        string
+		expected []string
+		hasError bool
+	}{
+		{` "a.txt"`, []string{"a.txt"}, false},
+		{` true # c`, []string{"true"}, false},
+		{` ["a.txt", 'b.txt' ,"c"] # c`, []string{"a.txt", "b.txt", "c"}, false},
+		{` ["a",]`, []string{"a"}, false},
+		{` []`, nil, false},
+		{` "a" "b"`, nil, true},
+		{` ["a" "b"]`, nil, true},
+		{` ["a"`, nil, true},
+		{` ["a"] x`, nil, true},
+		{` [yes]`, nil, true},
+	}
+	for _, mt := range tests {
+		vs, err := parseValue(mt.s)
+		if (err != nil) != mt.hasError || !slices.Equal(vs, mt.expected) {
+			t.Errorf("%q: %q %v", mt.s, vs, err)
+		}
+	}
+}
+
+func TestRunChecksAllJobs(t *testing.T) {
+	dir := t.TempDir()
+	config := filepath.Join(dir, "iprefix.toml")
+	in := filepath.Join(dir, "in.txt")
+	os.WriteFile(in, []byte("10.0.0.0/23\n"), 0o644)
+	os.WriteFile(config, []byte("input = \"in.txt\"\n"+
+		"[a]\noutput = \"a.txt\"\n"+
+		"[b]\noutput = \"b.txt\"\nfromat = \"cidr\"\n"+
+		"[c]\noutput = \"c.txt\"\n"), 0o644)
+	if ret := runRun("run", []string{config}); ret != 1 {
+		t.Error(ret)
+	}
+	// no job run
+	if _, err := os.Stat(filepath.Join(dir, "a.txt")); !os.IsNotExist(err) {
+		t.Error(err)
+	}
+
+	if ret := runRun("run", []string{"-job", "c", config}); ret != 0 {
+		t.Error(ret)
+	}
+	if b, err := os.ReadFile(filepath.Join(dir, "c.txt")); err != nil || string(b) != "# 10.0.0.0/23\n10.0.0.*\n10.0.1.*\n" {
+		t.Errorf("%q %v", b, err)
+	}
+
+	// scheduled, run once
+	os.WriteFile(config, []byte("input = \"in.txt\"\n[d]\noutput = \"d.txt\"\nschedule = \"1h\"\n"), 0o644)
+	if ret := runRun("run", []string{"-once", config}); ret != 0 {
+		t.Error(ret)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "d.txt")); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestParseSchedule(t *testing.T) {
+	dir := t.TempDir()
+	config := filepath.Join(dir, "iprefix.toml")
+	os.WriteFile(config, []byte("schedule = \"24h\"\ninput = \"in.txt\"\n"+
+		"[a]\n"+
+		"[b]\nschedule = \"90m\"\n"), 0o644)
+	jobs, err := parseConfig(config)
+	if err != nil || len(jobs) != 2 || jobs[0].every != 24*time.Hour || jobs[1].every != 90*time.Minute ||
+		len(jobs[1].args) != 1 {
+		t.Error(jobs, err)
+	}
+
+	for _, s := range []string{`"1d"`, `"30s"`, `["1h", "2h"]`, `1`} {
+		os.WriteFile(config, []byte("schedule = "+s+"\n"), 0o644)
+		if _, err := parseConfig(config); err == nil {
+			t.Error(s)
+		}
+	}
+}
+
+func TestNextRun(t *testing.T) {
+	last := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		now      time.Duration
+		expected time.Duration
+	}{
+		{time.Minute, time.Hour},
+		{time.Hour, 2 * time.Hour},
+		{150 * time.Minute, 3 * time.Hour},
+	}
+	for _, mt := range tests {
+		if r := nextRun(last, last.Add(mt.now), time.Hour); !r.Equal(last.Add(mt.expected)) {
+			t.Error(mt.now, r)
+		}
+	}
+}
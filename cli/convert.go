@@ -0,0 +1,119 @@
+// Copyright 2023-now by lifenjoiner. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"net/netip"
+	"os"
+
+	"github.com/lifenjoiner/iprefix"
+)
+
+// entryPrefixes returns the least prefixes of an entry: a pattern, a CIDR, an
+// IP range or an IP.
+func entryPrefixes(x string) ([]netip.Prefix, error) {
+	ps, err := iprefix.Aggregate([]string{x})
+	if ee := (*iprefix.EntryError)(nil); errors.As(err, &ee) {
+		err = ee.Err
+	}
+	return ps, err
+}
+
+func runConvert(prog string, args []string) int {
+	var inputFiles files
+	var outputFile, to string
+	cs := comments{"#"}
+	var maxLine int
+	var d diag
+
+	fs := flag.NewFlagSet(prog, flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s [-to cidr|range] [-c char] [-o file] [-f file]... [entry]...\n"+
+			"Converts each pattern, CIDR, IP range or IP to the least CIDRs, or an IP range.\n", prog)
+		fs.PrintDefaults()
+	}
+	fs.Var(&inputFiles, "f", "input `file` path, - for stdin, repeatable")
+	fs.StringVar(&outputFile, "o", "", "output file path, replaced only when all done")
+	fs.Var(&cs, "c", commentsUsage)
+	fs.IntVar(&maxLine, "maxline", 1024*1024, "max line length in bytes")
+	fs.StringVar(&to, "to", "cidr", "target notation, cidr or range")
+	d.flags(fs)
+	fs.Parse(args)
+
+	if to != "cidr" && to != "range" {
+		fmt.Fprintf(os.Stderr, "unknown notation: %s\n", to)
+		return 1
+	}
+	if err := d.check(); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return 1
+	}
+	args = fs.Args()
+	inputFiles, ok := inputs(inputFiles, args)
+	if !ok {
+		fs.Usage()
+		return 1
+	}
+	out, err := openOutput(outputFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return 1
+	}
+
+	line := func(s string) {
+		ss := cs.strip(s)
+		if len(ss) == 0 {
+			fmt.Fprintf(out, "%s\n", s)
+			return
+		}
+		x := firstEntry(ss)
+		ps, err := entryPrefixes(x)
+		if err != nil {
+			fmt.Fprintf(out, "%s\n", s)
+			d.warn(err)
+			return
+		}
+		d.trace("%s: %d prefixes", x, len(ps))
+		if to == "range" {
+			start, _ := iprefix.PrefixToRange(ps[0])
+			_, end := iprefix.PrefixToRange(ps[len(ps)-1])
+			fmt.Fprintf(out, "%s-%s\n", start, end)
+			return
+		}
+		for _, p := range ps {
+			fmt.Fprintf(out, "%s\n", p)
+		}
+	}
+	ret := 0
+	for _, file := range inputFiles {
+		d.at(file)
+		err := scanFile(file, maxLine, func(s string) error {
+			d.n++
+			line(s)
+			return nil
+		})
+		if errors.Is(err, bufio.ErrTooLong) {
+			err = d.wrap(err)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			ret = 1
+		}
+	}
+	d.at("arg")
+	for _, arg := range args {
+		d.n++
+		line(arg)
+	}
+	if err := out.close(ret == 0); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return 1
+	}
+	return ret
+}
@@ -0,0 +1,117 @@
+// Copyright 2023-now by lifenjoiner. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// decodeText returns `r` as UTF-8, dropping the BOM, and transcoding UTF-16
+// by the BOM, or by the zero byte of an ASCII leading character without it.
+func decodeText(r io.Reader) *bufio.Reader {
+	br, ok := r.(*bufio.Reader)
+	if !ok {
+		br = bufio.NewReader(r)
+	}
+	b, _ := br.Peek(3)
+	switch {
+	case len(b) == 3 && b[0] == 0xef && b[1] == 0xbb && b[2] == 0xbf:
+		br.Discard(3)
+	case len(b) >= 2 && b[0] == 0xff && b[1] == 0xfe:
+		br.Discard(2)
+		return bufio.NewReader(&utf16Reader{r: br, order: binary.LittleEndian})
+	case len(b) >= 2 && b[0] == 0xfe && b[1] == 0xff:
+		br.Discard(2)
+		return bufio.NewReader(&utf16Reader{r: br, order: binary.BigEndian})
+	case len(b) >= 2 && b[0] != 0 && b[0] < utf8.RuneSelf && b[1] == 0:
+		return bufio.NewReader(&utf16Reader{r: br, order: binary.LittleEndian})
+	case len(b) >= 2 && b[0] == 0 && b[1] != 0 && b[1] < utf8.RuneSelf:
+		return bufio.NewReader(&utf16Reader{r: br, order: binary.BigEndian})
+	}
+	return br
+}
+
+// isCRLF reports whether the first line of `br` is ended by CRLF, looking
+// only into what a read buffers.
+func isCRLF(br *bufio.Reader) bool {
+	br.Peek(1)
+	b, _ := br.Peek(br.Buffered())
+	i := bytes.IndexByte(b, '\n')
+	return i > 0 && b[i-1] == '\r'
+}
+
+// utf16Reader transcodes UTF-16 to UTF-8.
+type utf16Reader struct {
+	r       io.Reader
+	order   binary.ByteOrder
+	pending []byte
+	// held is the unit after a lone high surrogate, if hasHeld
+	held    rune
+	hasHeld bool
+}
+
+// unit reads a code unit.
+func (u *utf16Reader) unit() (rune, error) {
+	if u.hasHeld {
+		u.hasHeld = false
+		return u.held, nil
+	}
+	var b [2]byte
+	if _, err := io.ReadFull(u.r, b[:]); err != nil {
+		return 0, err
+	}
+	return rune(u.order.Uint16(b[:])), nil
+}
+
+// next reads a rune, lone surrogates as U+FFFD, keeping the unit after a
+// high one for the next rune.
+func (u *utf16Reader) next() (rune, error) {
+	r, err := u.unit()
+	if err != nil || !utf16.IsSurrogate(r) {
+		return r, err
+	}
+	if r >= 0xdc00 {
+		return utf8.RuneError, nil
+	}
+	r2, err := u.unit()
+	if err != nil {
+		return utf8.RuneError, nil
+	}
+	if d := utf16.DecodeRune(r, r2); d != utf8.RuneError {
+		return d, nil
+	}
+	u.held, u.hasHeld = r2, true
+	return utf8.RuneError, nil
+}
+
+func (u *utf16Reader) Read(p []byte) (n int, err error) {
+	n = copy(p, u.pending)
+	u.pending = u.pending[n:]
+	for n+utf8.UTFMax <= len(p) {
+		r, err := u.next()
+		if err != nil {
+			if n > 0 {
+				return n, nil
+			}
+			return 0, err
+		}
+		n += utf8.EncodeRune(p[n:], r)
+	}
+	if n == 0 && len(p) > 0 {
+		r, err := u.next()
+		if err != nil {
+			return 0, err
+		}
+		u.pending = utf8.AppendRune(u.pending[:0], r)
+		n = copy(p, u.pending)
+		u.pending = u.pending[n:]
+	}
+	return n, nil
+}
@@ -0,0 +1,40 @@
+// Copyright 2023-now by lifenjoiner. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"testing"
+)
+
+func TestUTF16Reader(t *testing.T) {
+	tests := []struct {
+		units    []uint16
+		expected string
+	}{
+		{[]uint16{'1', '0', '.', '*'}, "10.*"},
+		{[]uint16{0xd83d, 0xde00, '1'}, "\U0001f600" + "1"},
+		// lone high surrogates, keeping the next unit
+		{[]uint16{0xd83d, '1', '0'}, "\ufffd10"},
+		{[]uint16{0xd83d, 0xd83d, 0xde00}, "\ufffd\U0001f600"},
+		{[]uint16{'1', 0xd83d}, "1\ufffd"},
+		// a lone low surrogate
+		{[]uint16{0xde00, '1'}, "\ufffd1"},
+	}
+	for _, mt := range tests {
+		for _, order := range []binary.ByteOrder{binary.LittleEndian, binary.BigEndian} {
+			b := make([]byte, 2*len(mt.units))
+			for i, u := range mt.units {
+				order.PutUint16(b[2*i:], u)
+			}
+			r, err := io.ReadAll(&utf16Reader{r: bytes.NewReader(b), order: order})
+			if err != nil || string(r) != mt.expected {
+				t.Errorf("%x %v: %q %v", mt.units, order, r, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,60 @@
+// Copyright 2023-now by lifenjoiner. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+)
+
+// diag reports to stderr about the input lines by their positions, `name:n`.
+type diag struct {
+	name string
+	n    int
+	// quiet drops the warnings, verbose traces every entry
+	quiet, verbose bool
+}
+
+func (d *diag) flags(fs *flag.FlagSet) {
+	fs.BoolVar(&d.quiet, "q", false, "suppress the warnings about the unparsable entries")
+	fs.BoolVar(&d.verbose, "v", false, "trace every entry to stderr")
+}
+
+func (d *diag) check() error {
+	if d.quiet && d.verbose {
+		return errors.New("-q and -v exclude each other")
+	}
+	return nil
+}
+
+// at starts the lines of `name`.
+func (d *diag) at(name string) {
+	d.name, d.n = name, 0
+}
+
+// wrap prefixes `err` with the position, the next line if it is a too long
+// line that is not counted.
+func (d *diag) wrap(err error) error {
+	n := d.n
+	if errors.Is(err, bufio.ErrTooLong) {
+		n++
+	}
+	return fmt.Errorf("%s:%d: %w", d.name, n, err)
+}
+
+func (d *diag) warn(err error) {
+	if !d.quiet {
+		fmt.Fprintf(os.Stderr, "%s:%d: %v\n", d.name, d.n, err)
+	}
+}
+
+func (d *diag) trace(format string, a ...any) {
+	if d.verbose {
+		fmt.Fprintf(os.Stderr, "%s:%d: %s\n", d.name, d.n, fmt.Sprintf(format, a...))
+	}
+}
@@ -0,0 +1,103 @@
+// Copyright 2023-now by lifenjoiner. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE file.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/lifenjoiner/iprefix"
+)
+
+// texts returns the texts of the entries.
+func texts(es []entry) []string {
+	ts := make([]string, len(es))
+	for i, e := range es {
+		ts[i] = e.text
+	}
+	return ts
+}
+
+// cidrs converts the entries to the least CIDRs.
+func cidrs(ss []string) ([]string, error) {
+	ps, err := iprefix.Aggregate(ss)
+	if err != nil {
+		return nil, err
+	}
+	cs := make([]string, len(ps))
+	for i, p := range ps {
+		cs[i] = p.String()
+	}
+	return cs, nil
+}
+
+func runDiff(prog string, args []string) int {
+	var outputFile string
+	var cidr bool
+	cs := comments{"#"}
+	var maxLine int
+
+	fs := flag.NewFlagSet(prog, flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s [-cidr] [-c char] [-o file] old new\n"+
+			"Reports the IPs removed from old as `- pattern` lines, and those added in new\n"+
+			"as `+ pattern` lines, ignoring the order and the notation of the entries.\n"+
+			"Exits with 0 if the same, 1 if different, 2 on error.\n", prog)
+		fs.PrintDefaults()
+	}
+	fs.BoolVar(&cidr, "cidr", false, "report the least CIDRs instead of patterns")
+	fs.StringVar(&outputFile, "o", "", "output file path, replaced only when all done")
+	fs.Var(&cs, "c", commentsUsage)
+	fs.IntVar(&maxLine, "maxline", 1024*1024, "max line length in bytes")
+	fs.Parse(args)
+
+	if fs.NArg() != 2 {
+		fs.Usage()
+		return 2
+	}
+	var sets [2][]string
+	for i, path := range fs.Args() {
+		es, bad, err := readEntries([]string{path}, cs, maxLine, true)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			return 2
+		}
+		if bad > 0 {
+			return 2
+		}
+		sets[i] = texts(es)
+	}
+	added, removed, err := iprefix.DiffPatternTexts(sets[0], sets[1])
+	if err == nil && cidr {
+		if added, err = cidrs(added); err == nil {
+			removed, err = cidrs(removed)
+		}
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return 2
+	}
+
+	out, err := openOutput(outputFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return 2
+	}
+	for _, p := range removed {
+		fmt.Fprintf(out, "- %s\n", p)
+	}
+	for _, p := range added {
+		fmt.Fprintf(out, "+ %s\n", p)
+	}
+	if err := out.close(true); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return 2
+	}
+	if len(added)+len(removed) > 0 {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,40 @@
+// Copyright 2023-now by lifenjoiner. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE file.
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDiffExpansion(t *testing.T) {
+	for _, s := range []string{"1111:0:0:0:4444::/80", "1:0:0:4444::/64", "::/95", "10.0.0.0/23"} {
+		in := tempFile(t, "in.txt", s+"\n")
+		expanded := filepath.Join(t.TempDir(), "expanded.txt")
+		if ret := runExpand("cli", []string{"-no-echo", "-o", expanded, "-f", in}); ret != 0 {
+			t.Error(s, ret)
+			continue
+		}
+		out := filepath.Join(t.TempDir(), "out.txt")
+		if ret := runDiff("cli", []string{"-o", out, in, expanded}); ret != 0 {
+			t.Error(s, ret)
+		}
+		b, err := os.ReadFile(out)
+		if err != nil || len(b) > 0 {
+			t.Errorf("%s: %q %v", s, b, err)
+		}
+	}
+
+	// the IPs spelled with the zero blocks
+	old := tempFile(t, "old.txt", "1:0:0:4444::/64\n")
+	out := filepath.Join(t.TempDir(), "out.txt")
+	if ret := runDiff("cli", []string{"-o", out, old, tempFile(t, "new.txt", "1::4444:*\n")}); ret != 1 {
+		t.Error(ret)
+	}
+	if b, err := os.ReadFile(out); err != nil || string(b) != "- 1:0:0:4444:*\n" {
+		t.Errorf("%q %v", b, err)
+	}
+}
@@ -0,0 +1,65 @@
+// Copyright 2023-now by lifenjoiner. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"runtime"
+	"strings"
+)
+
+// eols are the line endings of the output.
+var eols = []string{"auto", "lf", "crlf", "native"}
+
+// eolWriter writes the LF line endings as CRLF if crlf. Unless fixed, crlf
+// follows the first input file.
+type eolWriter struct {
+	w     io.Writer
+	crlf  bool
+	fixed bool
+	buf   []byte
+}
+
+// newEOLWriter returns the writer of the line ending `eol` into `w`.
+func newEOLWriter(eol string, w io.Writer) (*eolWriter, error) {
+	ew := &eolWriter{w: w, fixed: true}
+	switch eol {
+	case "auto":
+		ew.fixed = false
+	case "lf":
+	case "crlf":
+		ew.crlf = true
+	case "native":
+		ew.crlf = runtime.GOOS == "windows"
+	default:
+		return nil, fmt.Errorf("unknown line ending: %s, not one of %s", eol, strings.Join(eols, ", "))
+	}
+	return ew, nil
+}
+
+// follow sets crlf by an input file if not fixed yet, fixing it.
+func (ew *eolWriter) follow(crlf bool) {
+	if !ew.fixed {
+		ew.crlf, ew.fixed = crlf, true
+	}
+}
+
+func (ew *eolWriter) Write(p []byte) (int, error) {
+	if !ew.crlf {
+		return ew.w.Write(p)
+	}
+	ew.buf = ew.buf[:0]
+	for _, c := range p {
+		if c == '\n' {
+			ew.buf = append(ew.buf, '\r')
+		}
+		ew.buf = append(ew.buf, c)
+	}
+	if _, err := ew.w.Write(ew.buf); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
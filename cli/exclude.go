@@ -0,0 +1,41 @@
+// Copyright 2023-now by lifenjoiner. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE file.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/lifenjoiner/iprefix"
+)
+
+// exclusion is the IPs of the entries of files, never expanded.
+type exclusion struct {
+	files files
+}
+
+func (ex *exclusion) flags(fs *flag.FlagSet) {
+	fs.Var(&ex.files, "x", "exclude the IPs of the entries of the `file` from the CIDRs and ranges, repeatable")
+}
+
+// option returns the option excluding the IPs, or nil if no file. The entries
+// are taken all together, for the ambiguous patterns.
+func (ex *exclusion) option(cs comments, maxLine int) (iprefix.Option, error) {
+	if len(ex.files) == 0 {
+		return nil, nil
+	}
+	es, bad, err := readEntries(ex.files, cs, maxLine, false)
+	if err != nil {
+		return nil, err
+	}
+	if bad > 0 {
+		return nil, fmt.Errorf("%d bad entries to exclude", bad)
+	}
+	ps, err := iprefix.Aggregate(texts(es))
+	if err != nil {
+		return nil, err
+	}
+	return iprefix.Exclude(ps...), nil
+}
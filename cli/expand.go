@@ -0,0 +1,365 @@
+// Copyright 2023-now by lifenjoiner. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/netip"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/lifenjoiner/iprefix"
+)
+
+// processor processes the input lines into `w`.
+type processor struct {
+	w *bufio.Writer
+	entryParser
+	outputFormat
+	// only4 and only6 leave the entries of the other family untouched
+	only4, only6 bool
+	// strict fails on the unparsable entries instead of passing them
+	strict bool
+	// wildcard ends the patterns, in opts
+	wildcard string
+	// opts tune the expansion, excluding the IPs of -x
+	opts []iprefix.Option
+	// eol is the line ending flag, ew writes it under w if not nil, and crlf
+	// is whether the last input file is CRLF ended
+	eol  string
+	ew   *eolWriter
+	crlf bool
+	// target is the application the output is checked for
+	target string
+	// rules is the kind of the rule files expanding only the IP fields
+	rules string
+	diag
+}
+
+// skips reports whether the entry is of the family left untouched.
+func (pp *processor) skips(x string) bool {
+	is6 := strings.ContainsRune(x, ':')
+	return pp.only4 && is6 || pp.only6 && !is6
+}
+
+// line processes a line, failing only in strict mode.
+func (pp *processor) line(s string) error {
+	ss := strings.TrimSpace(s)
+	content := pp.content(ss)
+	if len(content) == 0 {
+		pp.pass(s)
+		return nil
+	}
+	if len(pp.rules) > 0 {
+		return pp.ruleLine(s, ss, content)
+	}
+
+	var r *iprefix.Result
+	x, rest, err := pp.entry(content)
+	if err == nil {
+		if pp.skips(x) {
+			pp.trace("%s: skipped", x)
+			pp.pass(s)
+			return nil
+		}
+		if strings.ContainsAny(x, "/-") || !passesIPs(pp.format) {
+			r, err = iprefix.ProcessResult(x, pp.opts...)
+		} else if _, err = netip.ParseAddr(x); err == nil || !pp.strict {
+			pp.trace("%s: passed", x)
+			pp.pass(s)
+			return nil
+		}
+	}
+	if err == nil {
+		err = pp.emit(ss, pp.tail(rest), r)
+	}
+	if err != nil {
+		if pp.isHeader() {
+			pp.trace("header")
+			pp.pass(s)
+			return nil
+		}
+		if pp.strict {
+			return pp.wrap(err)
+		}
+		pp.warn(err)
+		pp.pass(s)
+		return nil
+	}
+	pp.trace("%s: %d patterns", x, len(r.Patterns))
+	return nil
+}
+
+// file processes the file of `path`, stdin if it is "-".
+func (pp *processor) file(path string) error {
+	pp.at(path)
+	br, done, err := openText(path)
+	if err != nil {
+		return err
+	}
+	defer done()
+	pp.crlf = isCRLF(br)
+	if pp.ew != nil {
+		pp.ew.follow(pp.crlf)
+	}
+	err = scanLines(br, pp.maxLine, func(line string) error {
+		pp.n++
+		return pp.line(strings.TrimSpace(line))
+	})
+	if errors.Is(err, bufio.ErrTooLong) {
+		err = pp.wrap(err)
+	}
+	return err
+}
+
+// inPlace is the in-place flag, with an optional backup suffix.
+type inPlace struct {
+	on     bool
+	suffix string
+}
+
+func (ip *inPlace) String() string {
+	return ip.suffix
+}
+
+func (ip *inPlace) Set(s string) error {
+	switch s {
+	case "true":
+		ip.on, ip.suffix = true, ""
+	case "false":
+		ip.on, ip.suffix = false, ""
+	default:
+		ip.on, ip.suffix = true, s
+	}
+	return nil
+}
+
+func (ip *inPlace) IsBoolFlag() bool {
+	return true
+}
+
+// inPlaceArgs rewrites `-i.bak` as `-i=.bak` for the flag package.
+func inPlaceArgs(args []string) []string {
+	for i, arg := range args {
+		if arg == "--" {
+			break
+		}
+		for _, p := range []string{"-i.", "--i."} {
+			if strings.HasPrefix(arg, p) {
+				args[i] = p[:len(p)-1] + "=" + arg[len(p)-1:]
+			}
+		}
+	}
+	return args
+}
+
+// editFile processes the file of `path` into itself, keeping the original
+// with `suffix` appended if not empty.
+func (pp *processor) editFile(path, suffix string) error {
+	compressed, err := isCompressed(path)
+	if err != nil {
+		return err
+	}
+	if compressed {
+		return fmt.Errorf("%s: compressed, unable to edit in place", path)
+	}
+	af, err := createAtomic(path)
+	if err != nil {
+		return err
+	}
+	defer af.Abort()
+	if pp.ew, err = newEOLWriter(pp.eol, af); err != nil {
+		return err
+	}
+	pp.w, pp.csv = bufio.NewWriter(pp.ew), nil
+	pp.begin()
+	if err = pp.file(path); err != nil {
+		return err
+	}
+	pp.end()
+	if err = pp.w.Flush(); err != nil {
+		return err
+	}
+	if len(suffix) > 0 {
+		if err = backup(path, path+suffix); err != nil {
+			return err
+		}
+	}
+	return af.Commit()
+}
+
+// expansion is a run of expand, parsed from its args.
+type expansion struct {
+	pp         *processor
+	args       []string
+	usage      func()
+	inputFiles files
+	outputFile string
+	edit       inPlace
+	jobs       int
+	exclude    exclusion
+}
+
+// parseExpand parses and checks the args of expand, the flag errors handled
+// by `handling`, and only returned without the usage if not exiting.
+func parseExpand(prog string, args []string, handling flag.ErrorHandling) (*expansion, error) {
+	x := &expansion{pp: &processor{}}
+	pp := x.pp
+
+	fs := flag.NewFlagSet(prog, handling)
+	if handling != flag.ExitOnError {
+		fs.SetOutput(io.Discard)
+	}
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s [-4|-6] [-c char] [-o file] [-f file]... [CIDR|IP1-IP2]...\n"+
+			"       %s -i[.bak] [-4|-6] [-c char] -f file...\n"+
+			"Reads stdin if file is `-`, or if piped without arguments.\n"+
+			"Run `%s help` for the other commands.\n", prog, prog, os.Args[0])
+		fs.PrintDefaults()
+	}
+	fs.Var(&x.inputFiles, "f", "input `file` or directory path, - for stdin, repeatable")
+	fs.StringVar(&x.outputFile, "o", "", "output file path, replaced only when all done")
+	fs.Var(&x.edit, "i", "edit the files in place, backing up with the suffix as -i.bak")
+	fs.IntVar(&x.jobs, "j", 1, "process the files by `N` workers, all CPUs if 0")
+	x.exclude.flags(fs)
+	pp.entryParser.flags(fs)
+	pp.outputFormat.flags(fs)
+	pp.diag.flags(fs)
+	fs.BoolVar(&pp.only4, "4", false, "process only IPv4 entries, passing the others through")
+	fs.BoolVar(&pp.only6, "6", false, "process only IPv6 entries, passing the others through")
+	fs.BoolVar(&pp.strict, "strict", false, "fail on the first unparsable entry, leaving the output files untouched")
+	fs.StringVar(&pp.wildcard, "wildcard", "*", "the `string` ending the patterns")
+	fs.StringVar(&pp.eol, "eol", "auto", "line ending of the output: "+strings.Join(eols, ", ")+
+		",\nauto follows the first input file")
+	fs.StringVar(&pp.target, "target", "", "check the output for the application: "+strings.Join(targets, ", ")+
+		",\ncommenting out the invalid passed lines")
+	fs.StringVar(&pp.rules, "rules", "", "expand only the CIDRs and IP ranges to IPs in the dnscrypt-proxy rule files: "+
+		strings.Join(rulesKinds, ", "))
+	if err := fs.Parse(inPlaceArgs(args)); err != nil {
+		return nil, err
+	}
+	x.args, x.usage = fs.Args(), fs.Usage
+	pp.opts = append(pp.opts, iprefix.Wildcard(pp.wildcard))
+
+	if err := pp.outputFormat.check(); err != nil {
+		return nil, err
+	}
+	if err := pp.entryParser.check(); err != nil {
+		return nil, err
+	}
+	if pp.only4 && pp.only6 {
+		return nil, errors.New("-4 and -6 exclude each other")
+	}
+	if !slices.Contains(eols, pp.eol) {
+		return nil, fmt.Errorf("unknown line ending: %s, not one of %s", pp.eol, strings.Join(eols, ", "))
+	}
+	if err := pp.checkTarget(); err != nil {
+		return nil, err
+	}
+	if err := pp.checkRules(); err != nil {
+		return nil, err
+	}
+	if len(pp.wildcard) == 0 {
+		return nil, errors.New("-wildcard is empty")
+	}
+	if pp.sort && (isRecord(pp.format) || x.edit.on) {
+		return nil, errors.New("-sort takes neither -i nor the json and csv formats")
+	}
+	if err := pp.diag.check(); err != nil {
+		return nil, err
+	}
+	if x.edit.on && (len(x.inputFiles) == 0 || len(x.args) > 0 || len(x.outputFile) > 0 || slices.Contains(x.inputFiles, "-")) {
+		return nil, errors.New("-i takes only files by -f")
+	}
+	return x, nil
+}
+
+func runExpand(prog string, args []string) int {
+	x, err := parseExpand(prog, args, flag.ExitOnError)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return 1
+	}
+	return x.run()
+}
+
+// run runs the expansion, returning the exit code.
+func (x *expansion) run() int {
+	pp, args := x.pp, x.args
+	exclude, err := x.exclude.option(pp.cs, pp.maxLine)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return 1
+	}
+	if exclude != nil {
+		pp.opts = append(pp.opts, exclude)
+	}
+
+	if x.edit.on {
+		inputFiles, err := walkFiles(x.inputFiles)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			return 1
+		}
+		if !pp.editFiles(inputFiles, x.edit.suffix, x.jobs) {
+			return 1
+		}
+		return 0
+	}
+	inputFiles, ok := inputs(x.inputFiles, args)
+	if !ok {
+		x.usage()
+		return 1
+	}
+	if inputFiles, err = walkFiles(inputFiles); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return 1
+	}
+
+	out, err := openOutput(x.outputFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return 1
+	}
+	if pp.ew, err = newEOLWriter(pp.eol, out.Writer); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return 1
+	}
+	pp.w = bufio.NewWriter(pp.ew)
+	pp.begin()
+	ret := 0
+	if !pp.files(inputFiles, x.jobs) {
+		ret = 1
+	}
+	pp.at("arg")
+	for _, arg := range args {
+		if ret != 0 && pp.strict {
+			break
+		}
+		pp.n++
+		if err := pp.line(arg); err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			ret = 1
+		}
+	}
+	if pp.sort {
+		pp.flushHeld()
+	}
+	pp.end()
+	if err := pp.w.Flush(); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		ret = 1
+	}
+	if err := out.close(ret == 0); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return 1
+	}
+	return ret
+}
@@ -0,0 +1,104 @@
+// Copyright 2023-now by lifenjoiner. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE file.
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// tempFile writes `content` to a file of the test, and returns its path.
+func tempFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestExpandNetmask(t *testing.T) {
+	tests := []struct {
+		in       string
+		expected string
+	}{
+		{"10.0.0.0 255.255.254.0\n", "10.0.0.*\n10.0.1.*\n"},
+		{"10.0.0.0\t255.255.255.0 note\n", "10.0.0.* note\n"},
+		// not the network address
+		{"10.0.0.1 255.255.254.0\n", "10.0.0.1 255.255.254.0\n"},
+		// not IPv4 netmasks, passed
+		{"10.0.0.0 1::255.255.255.0\n", "10.0.0.0 1::255.255.255.0\n"},
+		{"10.0.0.0 ::ffff:255.255.255.0\n", "10.0.0.0 ::ffff:255.255.255.0\n"},
+		{"1:: 255.255.255.0\n", "1:: 255.255.255.0\n"},
+	}
+	for _, mt := range tests {
+		out := filepath.Join(t.TempDir(), "out.txt")
+		if ret := runExpand("cli", []string{"-no-echo", "-fields", "-o", out, "-f", tempFile(t, "in.txt", mt.in)}); ret != 0 {
+			t.Error(mt.in, ret)
+			continue
+		}
+		b, err := os.ReadFile(out)
+		if err != nil || string(b) != mt.expected {
+			t.Errorf("%q: %q %v", mt.in, b, err)
+		}
+	}
+}
+
+func TestExpandExclude(t *testing.T) {
+	tests := []struct {
+		format   string
+		expected string
+	}{
+		{"json", `{"input":"10.0.0.0/22","family":4,"patterns":["10.0.0.*","10.0.2.*","10.0.3.*"],"count":768}` + "\n"},
+		{"cidr", "10.0.0.0/24\n10.0.2.0/23\n"},
+		{"csv", "entry,pattern\n10.0.0.0/22,10.0.0.*\n10.0.0.0/22,10.0.2.*\n10.0.0.0/22,10.0.3.*\n"},
+	}
+	in := tempFile(t, "in.txt", "10.0.0.0/22\n")
+	exclude := tempFile(t, "x.txt", "10.0.1.*\n")
+	for _, mt := range tests {
+		out := filepath.Join(t.TempDir(), "out.txt")
+		if ret := runExpand("cli", []string{"-no-echo", "-format", mt.format, "-x", exclude, "-o", out, "-f", in}); ret != 0 {
+			t.Error(mt.format, ret)
+			continue
+		}
+		b, err := os.ReadFile(out)
+		if err != nil || string(b) != mt.expected {
+			t.Errorf("%s: %q %v", mt.format, b, err)
+		}
+	}
+
+	// the IPv6 spellings of a prefix excluded together
+	in = tempFile(t, "in.txt", "1111::/31\n")
+	exclude = tempFile(t, "x.txt", "1111:0:*\n1111::*\n1111::\n")
+	for format, expected := range map[string]string{"cidr": "1111:1::/32\n", "nginx": "deny 1111:1::/32;\n"} {
+		out := filepath.Join(t.TempDir(), "out.txt")
+		if ret := runExpand("cli", []string{"-no-echo", "-format", format, "-x", exclude, "-o", out, "-f", in}); ret != 0 {
+			t.Error(format, ret)
+			continue
+		}
+		b, err := os.ReadFile(out)
+		if err != nil || string(b) != expected {
+			t.Errorf("%s: %q %v", format, b, err)
+		}
+	}
+}
+
+func TestExpandCSVHeader(t *testing.T) {
+	// the first file has no record
+	a := tempFile(t, "a.txt", "# none\n")
+	b := tempFile(t, "b.txt", "10.0.0.0/24\n")
+	for _, j := range []string{"1", "2"} {
+		out := filepath.Join(t.TempDir(), "out.txt")
+		if ret := runExpand("cli", []string{"-format", "csv", "-j", j, "-o", out, "-f", a, "-f", b, "10.0.1.0/24"}); ret != 0 {
+			t.Error(j, ret)
+			continue
+		}
+		r, err := os.ReadFile(out)
+		if err != nil || string(r) != "entry,pattern\n10.0.0.0/24,10.0.0.*\n10.0.1.0/24,10.0.1.*\n" {
+			t.Errorf("-j %s: %q %v", j, r, err)
+		}
+	}
+}
@@ -0,0 +1,379 @@
+// Copyright 2023-now by lifenjoiner. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE file.
+
+package main
+
+import (
+	"cmp"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net/netip"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/lifenjoiner/iprefix"
+)
+
+// formats are the output formats of expand.
+var formats = []string{"plain", "cidr", "json", "csv", "regex", "adguard", "smartdns", "privoxy", "nginx", "nginx-geo"}
+
+// directives are the directives of the formats, the first is the default.
+var directives = map[string][]string{
+	"smartdns": {"blacklist-ip", "whitelist-ip", "ignore-ip", "bogus-nxdomain"},
+	"nginx":    {"deny", "allow"},
+}
+
+// marks are the comment marks of the formats of applications.
+var marks = map[string]string{
+	"adguard":  "!",
+	"smartdns": "#",
+	"privoxy":  "#",
+	"nginx":    "#",
+	// the lines of the map are indented
+	"nginx-geo": "    #",
+}
+
+// outputFormat is the format of the output lines.
+type outputFormat struct {
+	format string
+	csv    *csv.Writer
+	// directive is the directive of the format writing them
+	directive string
+	// action is the actions of the privoxy format
+	action string
+	// geo is the variable of the nginx-geo format, geoDefault its default
+	geo, geoDefault string
+	// fields appends the fields after the entry to the patterns, then note
+	fields bool
+	note   string
+	// noEcho omits the entries, keep leaves them uncommented
+	noEcho, keep bool
+	// sort holds the pattern lines to write them sorted at last, dropping the
+	// others
+	sort bool
+	held []held
+}
+
+func (of *outputFormat) flags(fs *flag.FlagSet) {
+	fs.StringVar(&of.format, "format", "plain", "output format: "+strings.Join(formats, ", ")+
+		",\njson and csv only write the records of the entries")
+	fs.StringVar(&of.directive, "directive", "", "the directive of the smartdns and nginx formats, blacklist-ip and deny by default")
+	fs.StringVar(&of.action, "action", "+block{IP blocklist}", "the `actions` of the privoxy format section")
+	fs.StringVar(&of.geo, "geo", "ip_blocked", "the `variable` of the nginx-geo format map, without $,\nvalued by the -fields and -note of the entries, or 1")
+	fs.StringVar(&of.geoDefault, "geo-default", "0", "the default `value` of the nginx-geo format map")
+	fs.BoolVar(&of.fields, "fields", false, "append the fields after the entry to its pattern lines")
+	fs.StringVar(&of.note, "note", "", "append the `text` to the pattern lines, after the fields")
+	fs.BoolVar(&of.noEcho, "no-echo", false, "omit the entries instead of commenting them")
+	fs.BoolVar(&of.keep, "keep", false, "keep the entries uncommented")
+	fs.BoolVar(&of.sort, "sort", false, "write only the pattern lines of the whole run, sorted by address and without duplicates")
+}
+
+// tail returns the end of the pattern lines of an entry, of the fields `rest`
+// after it.
+func (of *outputFormat) tail(rest string) string {
+	var ts []string
+	if of.fields && len(rest) > 0 {
+		ts = append(ts, rest)
+	}
+	if len(of.note) > 0 {
+		ts = append(ts, of.note)
+	}
+	tail := strings.Join(ts, " ")
+	switch {
+	case of.format == "nginx-geo":
+		// the fields and note are the value of the map
+		return " " + nginxValue(cmp.Or(tail, "1")) + ";"
+	case len(tail) > 0:
+		return " " + tail
+	}
+	return ""
+}
+
+// isCIDR reports whether the format writes the least CIDRs of entries.
+func isCIDR(format string) bool {
+	return format == "cidr" || format == "smartdns" || format == "nginx" || format == "nginx-geo"
+}
+
+// hasTail reports whether the format takes the fields and notes after the
+// patterns.
+func hasTail(format string) bool {
+	return format != "adguard" && format != "smartdns" && format != "privoxy" && format != "nginx"
+}
+
+// passesIPs reports whether the format takes the IP entries as they are,
+// passing them. The formats of applications write their own lines of them.
+func passesIPs(format string) bool {
+	_, ok := marks[format]
+	return !ok
+}
+
+// isRecord reports whether the format only writes the records of entries,
+// dropping the other lines.
+func isRecord(format string) bool {
+	return format == "json" || format == "csv"
+}
+
+// patternRegexp returns the regular expression matching what the pattern
+// matches.
+func patternRegexp(p string) string {
+	if head, ok := strings.CutSuffix(p, "*"); ok {
+		return "^" + regexp.QuoteMeta(head)
+	}
+	return "^" + regexp.QuoteMeta(p) + "$"
+}
+
+// adguardRule returns the AdGuard Home rule blocking the DNS responses of the
+// IPs the pattern matches, anchored at the start of the IP.
+func adguardRule(p string) string {
+	if head, ok := strings.CutSuffix(p, "*"); ok {
+		return "|" + head
+	}
+	return "|" + p + "^"
+}
+
+// privoxyPattern returns the Privoxy host pattern matching the IPs the
+// pattern matches: the IPv4 prefixes ended by the dot anchor the left labels,
+// and the IPv6 IPs are bracketed. The IPv6 prefixes have none.
+func privoxyPattern(p string) (string, error) {
+	head, wild := strings.CutSuffix(p, "*")
+	switch {
+	case !strings.ContainsRune(p, ':'):
+		return head, nil
+	case !wild:
+		return "[" + p + "]", nil
+	}
+	return "", fmt.Errorf("no privoxy pattern of the IPv6 prefix: %s", p)
+}
+
+// nginxValue returns the nginx parameter of `s`, quoted if it is not a
+// single token.
+func nginxValue(s string) string {
+	if len(s) == 0 || strings.ContainsAny(s, " \t\r\n;{}#\"'\\$") {
+		return strconv.Quote(s)
+	}
+	return s
+}
+
+// begin writes the head of an output.
+func (pp *processor) begin() {
+	switch pp.format {
+	case "privoxy":
+		fmt.Fprintf(pp.w, "{ %s }\n", pp.action)
+	case "nginx-geo":
+		fmt.Fprintf(pp.w, "geo $%s {\n    default %s;\n", pp.geo, nginxValue(pp.geoDefault))
+	}
+}
+
+// end writes the foot of an output, after the held lines.
+func (pp *processor) end() {
+	if pp.format == "nginx-geo" {
+		fmt.Fprintf(pp.w, "}\n")
+	}
+}
+
+// pass writes a line not expanded, commented out if invalid for the target.
+func (pp *processor) pass(s string) {
+	if isRecord(pp.format) || pp.sort {
+		return
+	}
+	if err := pp.checkLine(s); err != nil {
+		pp.trace("%v, commented out", err)
+		s = pp.mark() + " " + s
+	}
+	fmt.Fprintf(pp.w, "%s\n", s)
+}
+
+// emit writes the result `r` of the entry of the line `ss` in the format,
+// the lines of it ended by `tail`, see outputFormat.tail.
+func (pp *processor) emit(ss, tail string, r *iprefix.Result) error {
+	w := pp.w
+	switch pp.format {
+	case "json":
+		b, err := json.Marshal(r)
+		if err != nil {
+			return err
+		}
+		w.Write(b)
+		w.WriteByte('\n')
+		return nil
+	case "csv":
+		cw := pp.csvWriter()
+		for _, p := range r.Patterns {
+			cw.Write([]string{r.Input, p})
+		}
+		cw.Flush()
+		return cw.Error()
+	}
+
+	// the lines before the echo, so an invalid entry is only passed
+	lines, err := pp.lines(r)
+	if err != nil {
+		return err
+	}
+	for _, h := range lines {
+		if err := pp.checkLine(h.text + tail); err != nil {
+			return err
+		}
+	}
+	switch {
+	case pp.sort:
+	case pp.keep:
+		fmt.Fprintf(w, "%s\n", ss)
+	case !pp.noEcho:
+		fmt.Fprintf(w, "%s %s\n", pp.mark(), ss)
+	}
+	for _, h := range lines {
+		pp.put(h.key, h.text, tail)
+	}
+	return nil
+}
+
+// csvWriter returns the CSV writer of `w`, writing the header before the
+// first record.
+func (pp *processor) csvWriter() *csv.Writer {
+	if pp.csv == nil {
+		pp.csv = csv.NewWriter(pp.w)
+		pp.csv.Write([]string{"entry", "pattern"})
+	}
+	return pp.csv
+}
+
+// lines returns the output lines of the result `r` in the format, keyed only
+// for -sort.
+func (pp *processor) lines(r *iprefix.Result) ([]held, error) {
+	if isCIDR(pp.format) {
+		hs := make([]held, len(r.Prefixes))
+		for i, p := range r.Prefixes {
+			hs[i] = held{key: p, text: pp.cidrText(p)}
+		}
+		return hs, nil
+	}
+	hs := make([]held, len(r.Patterns))
+	for i, p := range r.Patterns {
+		if pp.sort {
+			hs[i].key, _ = iprefix.ParsePattern(pp.unwild(p))
+		}
+		hs[i].text = p
+		switch pp.format {
+		case "regex":
+			hs[i].text = patternRegexp(pp.unwild(p))
+		case "adguard":
+			hs[i].text = adguardRule(pp.unwild(p))
+		case "privoxy":
+			var err error
+			if hs[i].text, err = privoxyPattern(pp.unwild(p)); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return hs, nil
+}
+
+// unwild restores the wildcard "*" of a pattern ended by the custom one.
+func (pp *processor) unwild(p string) string {
+	if pp.wildcard == "*" {
+		return p
+	}
+	if head, ok := strings.CutSuffix(p, pp.wildcard); ok {
+		return head + "*"
+	}
+	return p
+}
+
+// held is an output line held for -sort, ordered by the prefix `key` it
+// stands for.
+type held struct {
+	key        netip.Prefix
+	text, tail string
+}
+
+// compareHeld orders by address, IPv4 before IPv6, then the broader before
+// the narrower.
+func compareHeld(a, b held) int {
+	if c := a.key.Addr().Compare(b.key.Addr()); c != 0 {
+		return c
+	}
+	if c := a.key.Bits() - b.key.Bits(); c != 0 {
+		return c
+	}
+	return cmp.Or(strings.Compare(a.text, b.text), strings.Compare(a.tail, b.tail))
+}
+
+// put writes an output line, or holds it for -sort.
+func (pp *processor) put(key netip.Prefix, text, tail string) {
+	if pp.sort {
+		pp.held = append(pp.held, held{key, text, tail})
+		return
+	}
+	fmt.Fprintf(pp.w, "%s%s\n", text, tail)
+}
+
+// flushHeld writes the held lines sorted, without duplicates.
+func (pp *processor) flushHeld() {
+	slices.SortFunc(pp.held, compareHeld)
+	for _, h := range slices.Compact(pp.held) {
+		fmt.Fprintf(pp.w, "%s%s\n", h.text, h.tail)
+	}
+	pp.held = nil
+}
+
+// cidrText returns the text of a CIDR in the format.
+func (of *outputFormat) cidrText(p netip.Prefix) string {
+	switch of.format {
+	case "smartdns":
+		return of.directive + " " + p.String()
+	case "nginx":
+		return of.directive + " " + p.String() + ";"
+	case "nginx-geo":
+		return "    " + p.String()
+	}
+	return p.String()
+}
+
+// isNginxVar reports whether `s` is a name of an nginx variable, without the
+// leading "$".
+func isNginxVar(s string) bool {
+	if len(s) == 0 {
+		return false
+	}
+	for _, c := range s {
+		if c != '_' && !('a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9') {
+			return false
+		}
+	}
+	return true
+}
+
+// check checks the format is known and fits the flags, and sets the default
+// directive.
+func (of *outputFormat) check() error {
+	if !slices.Contains(formats, of.format) {
+		return fmt.Errorf("unknown format: %s, not one of %s", of.format, strings.Join(formats, ", "))
+	}
+	if !hasTail(of.format) && (of.fields || len(of.note) > 0) {
+		return fmt.Errorf("-format %s takes no -fields or -note", of.format)
+	}
+	if of.format == "nginx-geo" && !isNginxVar(of.geo) {
+		return fmt.Errorf("bad nginx variable name: %s", of.geo)
+	}
+	if of.noEcho && of.keep {
+		return errors.New("-no-echo and -keep exclude each other")
+	}
+	ds, ok := directives[of.format]
+	switch {
+	case !ok && len(of.directive) > 0:
+		return fmt.Errorf("-format %s takes no -directive", of.format)
+	case !ok:
+	case len(of.directive) == 0:
+		of.directive = ds[0]
+	case !slices.Contains(ds, of.directive):
+		return fmt.Errorf("unknown directive: %s, not one of %s", of.directive, strings.Join(ds, ", "))
+	}
+	return nil
+}
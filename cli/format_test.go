@@ -0,0 +1,27 @@
+// Copyright 2023-now by lifenjoiner. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE file.
+
+package main
+
+import "testing"
+
+func TestOutputFormatTail(t *testing.T) {
+	tests := []struct {
+		of       outputFormat
+		rest     string
+		expected string
+	}{
+		{outputFormat{format: "plain"}, "a b", ""},
+		{outputFormat{format: "plain", fields: true}, "a b", " a b"},
+		{outputFormat{format: "plain", fields: true, note: "n"}, "a", " a n"},
+		{outputFormat{format: "plain", note: "n"}, "a", " n"},
+		{outputFormat{format: "nginx-geo"}, "a", " 1;"},
+		{outputFormat{format: "nginx-geo", fields: true}, "a b", ` "a b";`},
+	}
+	for _, mt := range tests {
+		if r := mt.of.tail(mt.rest); r != mt.expected {
+			t.Errorf("%+v %q: %q", mt.of, mt.rest, r)
+		}
+	}
+}
@@ -0,0 +1,256 @@
+// Copyright 2023-now by lifenjoiner. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net/netip"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/lifenjoiner/iprefix"
+)
+
+// columns is the CSV columns flag, separated by commas.
+type columns []int
+
+func (cs *columns) String() string {
+	ss := make([]string, len(*cs))
+	for i, c := range *cs {
+		ss[i] = strconv.Itoa(c)
+	}
+	return strings.Join(ss, ",")
+}
+
+func (cs *columns) Set(s string) error {
+	*cs = nil
+	for _, f := range strings.Split(s, ",") {
+		c, err := strconv.Atoi(strings.TrimSpace(f))
+		if err != nil {
+			return err
+		}
+		*cs = append(*cs, c)
+	}
+	return nil
+}
+
+// entryParser parses the entries of the input lines.
+type entryParser struct {
+	cs      comments
+	maxLine int
+	// csvIn reads the entries from the CSV column col, or the range of the
+	// start and end columns of rangeCols
+	csvIn     bool
+	col       int
+	rangeCols columns
+	// ndjson reads the entries from the JSON object lines, the CIDR field of
+	// the name cidrField, or the range of the startField and endField ones
+	ndjson                          bool
+	cidrField, startField, endField string
+}
+
+func (ep *entryParser) flags(fs *flag.FlagSet) {
+	ep.cs = comments{"#"}
+	fs.Var(&ep.cs, "c", commentsUsage)
+	fs.IntVar(&ep.maxLine, "maxline", 1024*1024, "max line length in bytes")
+	fs.BoolVar(&ep.csvIn, "csv", false, "read the entries from a CSV column, the first record failing taken as the header")
+	fs.IntVar(&ep.col, "col", 1, "the CSV column of the entries, from 1")
+	fs.Var(&ep.rangeCols, "range-cols", "the CSV `start,end` columns of the range entries")
+	fs.BoolVar(&ep.ndjson, "ndjson", false, "read the entries from the JSON object lines")
+	fs.StringVar(&ep.cidrField, "cidr-field", "cidr", "the JSON field of the CIDR entries")
+	fs.StringVar(&ep.startField, "start-field", "start", "the JSON field of the range starts")
+	fs.StringVar(&ep.endField, "end-field", "end", "the JSON field of the range ends")
+}
+
+// check checks the CSV columns are valid.
+func (ep *entryParser) check() error {
+	if len(ep.rangeCols) > 0 && len(ep.rangeCols) != 2 {
+		return errors.New("-range-cols takes 2 columns")
+	}
+	for _, c := range append([]int{ep.col}, ep.rangeCols...) {
+		if c < 1 {
+			return fmt.Errorf("bad column: %d", c)
+		}
+	}
+	if !ep.csvIn && (len(ep.rangeCols) > 0 || ep.col != 1) {
+		return errors.New("-col and -range-cols take -csv")
+	}
+	if ep.csvIn && ep.ndjson {
+		return errors.New("-csv and -ndjson exclude each other")
+	}
+	return nil
+}
+
+// content returns the content of a trimmed line, the comments stripped unless
+// a JSON object.
+func (ep *entryParser) content(ss string) string {
+	if ep.ndjson && strings.HasPrefix(ss, "{") {
+		// no inline comment in JSON
+		return ss
+	}
+	return ep.cs.strip(ss)
+}
+
+// entry returns the entry of the content of a line, and the rest fields.
+func (ep *entryParser) entry(content string) (x, rest string, err error) {
+	if ep.csvIn {
+		return ep.csvEntry(content)
+	}
+	if ep.ndjson {
+		return ep.jsonEntry(content)
+	}
+	if x, rest, ok := commaRange(content); ok {
+		return x, rest, nil
+	}
+	content = strings.Replace(content, "\t", " ", 1)
+	x, rest, _ = strings.Cut(content, " ")
+	x, rest = strings.TrimSpace(x), strings.TrimSpace(rest)
+	if p, mask, ok := netmaskEntry(x, rest); ok {
+		x, rest = p.String(), strings.TrimSpace(strings.TrimPrefix(rest, mask))
+	}
+	return x, rest, nil
+}
+
+// firstEntry returns the entry of a line content, the first field, or the
+// prefix of an address and netmask pair.
+func firstEntry(content string) string {
+	if x, _, ok := commaRange(content); ok {
+		return x
+	}
+	f := strings.Fields(content)
+	if len(f) > 1 {
+		if p, _, ok := netmaskEntry(f[0], f[1]); ok {
+			return p.String()
+		}
+	}
+	return f[0]
+}
+
+// commaRange returns the range `IP1-IP2` of a content leading with `IP1,IP2`,
+// and the rest fields after them.
+func commaRange(content string) (x, rest string, ok bool) {
+	start, tail, ok := strings.Cut(content, ",")
+	if !ok {
+		return
+	}
+	start = strings.TrimSpace(start)
+	tail = strings.TrimLeft(tail, " \t")
+	end := tail
+	if i := strings.IndexAny(tail, " \t,"); i >= 0 {
+		end, rest = tail[:i], tail[i:]
+	}
+	if _, err := netip.ParseAddr(start); err != nil {
+		return "", "", false
+	}
+	if _, err := netip.ParseAddr(end); err != nil {
+		return "", "", false
+	}
+	rest = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(rest), ","))
+	return start + "-" + end, rest, true
+}
+
+// netmaskEntry returns the prefix of the address `x` and the netmask leading
+// `rest`, only if `x` is the network address.
+func netmaskEntry(x, rest string) (p netip.Prefix, mask string, ok bool) {
+	f := strings.Fields(rest)
+	if len(f) == 0 {
+		return
+	}
+	addr, err := netip.ParseAddr(x)
+	if err != nil || !addr.Is4() {
+		return
+	}
+	if m, err := netip.ParseAddr(f[0]); err != nil || !m.Is4() {
+		return
+	}
+	if p, err = iprefix.ParseNetmask(x + " " + f[0]); err != nil {
+		return
+	}
+	return p, f[0], addr == p.Addr()
+}
+
+// csvEntry returns the entry of a CSV record, and the other fields.
+func (ep *entryParser) csvEntry(s string) (x, rest string, err error) {
+	r := csv.NewReader(strings.NewReader(s))
+	r.FieldsPerRecord = -1
+	r.LazyQuotes = true
+	rec, err := r.Read()
+	if err != nil {
+		return "", "", err
+	}
+	cols := []int{ep.col}
+	if len(ep.rangeCols) > 0 {
+		cols = ep.rangeCols
+	}
+	var xs []string
+	for _, c := range cols {
+		if c > len(rec) {
+			return "", "", fmt.Errorf("no column %d", c)
+		}
+		xs = append(xs, strings.TrimSpace(rec[c-1]))
+	}
+	var others []string
+	for i, f := range rec {
+		if !slices.Contains(cols, i+1) {
+			others = append(others, f)
+		}
+	}
+	return strings.Join(xs, "-"), strings.Join(others, ","), nil
+}
+
+// jsonEntry returns the entry of a JSON object, and the other fields as a
+// JSON object if any.
+func (ep *entryParser) jsonEntry(s string) (x, rest string, err error) {
+	var obj map[string]json.RawMessage
+	if err = json.Unmarshal([]byte(s), &obj); err != nil {
+		return "", "", err
+	}
+	field := func(name string) (string, bool, error) {
+		raw, ok := obj[name]
+		if !ok {
+			return "", false, nil
+		}
+		delete(obj, name)
+		var v string
+		if err := json.Unmarshal(raw, &v); err != nil {
+			return "", true, fmt.Errorf("field %s: %w", name, err)
+		}
+		return strings.TrimSpace(v), true, nil
+	}
+	x, ok, err := field(ep.cidrField)
+	if err == nil && !ok {
+		var start, end string
+		var okStart, okEnd bool
+		if start, okStart, err = field(ep.startField); err == nil {
+			end, okEnd, err = field(ep.endField)
+		}
+		if err == nil && !(okStart && okEnd) {
+			err = fmt.Errorf("no field %s, or %s and %s", ep.cidrField, ep.startField, ep.endField)
+		}
+		x = start + "-" + end
+	}
+	if err != nil {
+		return "", "", err
+	}
+	if len(obj) > 0 {
+		b, err := json.Marshal(obj)
+		if err != nil {
+			return "", "", err
+		}
+		rest = string(b)
+	}
+	return x, rest, nil
+}
+
+// isHeader reports whether the line is the first one of a CSV input, taken as
+// the header if it fails.
+func (pp *processor) isHeader() bool {
+	return pp.csvIn && pp.n == 1
+}
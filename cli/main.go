@@ -5,90 +5,206 @@
 package main
 
 import (
-	"flag"
+	"bufio"
 	"fmt"
+	"io"
 	"os"
 	"strings"
-
-	"github.com/lifenjoiner/iprefix"
 )
 
-func processLine(s string, cc string) {
-	ss := strings.TrimSpace(s)
-	if len(ss) == 0 || ss[:len(cc)] == cc {
-		fmt.Printf("%s\n", s)
-		return
-	}
-
-	ss = strings.Replace(ss, "\t", " ", 1)
-	p := strings.SplitN(ss, " ", 2)
-	x := strings.TrimSpace(p[0])
-
-	var pr []string
-	var err error
-	if strings.ContainsRune(x, '/') {
-		pr, err = iprefix.ProcessCIDR(x)
-	} else {
-		r := strings.SplitN(x, "-", 2)
-		switch len(r) {
-		case 2:
-			pr, err = iprefix.ProcessRange(r[0], r[1])
-		case 1:
-			fmt.Printf("%s\n", s)
-			return
+// command is a subcommand of the tool.
+type command struct {
+	name    string
+	summary string
+	run     func(prog string, args []string) int
+}
+
+var commands []command
+
+func init() {
+	commands = []command{
+		{"expand", "expand CIDRs and IP ranges into patterns, the default", runExpand},
+		{"convert", "convert entries between CIDRs and IP ranges", runConvert},
+		{"match", "print the entries matching IPs", runMatch},
+		{"merge", "merge entries into the least patterns", runMerge},
+		{"revert", "merge entries into the least CIDRs", runRevert},
+		{"stats", "report the statistics of entry files", runStats},
+		{"diff", "report the IPs added and removed between two entry files", runDiff},
+		{"run", "run the expand jobs of a config file", runRun},
+		{"help", "show the commands", runHelp},
+	}
+}
+
+func runHelp(prog string, args []string) int {
+	name := strings.TrimSuffix(prog, " help")
+	fmt.Fprintf(os.Stderr, "Usage: %s [command] [flags] [args]\n\nCommands:\n", name)
+	for _, c := range commands {
+		fmt.Fprintf(os.Stderr, "  %-8s %s\n", c.name, c.summary)
+	}
+	fmt.Fprintf(os.Stderr, "\nRun `%s command -h` for the flags of a command.\n", name)
+	return 0
+}
+
+// isPiped reports whether `f` is not a terminal, but a pipe or a file.
+func isPiped(f *os.File) bool {
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice == 0
+}
+
+// files is the repeated file flag.
+type files []string
+
+func (fs *files) String() string {
+	return strings.Join(*fs, ",")
+}
+
+func (fs *files) Set(s string) error {
+	*fs = append(*fs, s)
+	return nil
+}
+
+// comments is the comment prefixes flag, separated by commas.
+type comments []string
+
+func (cs *comments) String() string {
+	return strings.Join(*cs, ",")
+}
+
+func (cs *comments) Set(s string) error {
+	*cs = nil
+	for _, c := range strings.Split(s, ",") {
+		if c = strings.TrimSpace(c); len(c) > 0 {
+			*cs = append(*cs, c)
 		}
 	}
+	return nil
+}
+
+// strip returns the content of a line before the comment, trimmed, blank for
+// a comment line.
+func (cs comments) strip(line string) string {
+	i := len(line)
+	for _, c := range cs {
+		if j := strings.Index(line[:i], c); j >= 0 {
+			i = j
+		}
+	}
+	return strings.TrimSpace(line[:i])
+}
+
+// mark returns the prefix to comment lines with.
+func (cs comments) mark() string {
+	if len(cs) == 0 {
+		return "#"
+	}
+	return cs[0]
+}
+
+const commentsUsage = "comment prefixes, separated by commas, also inline after entries"
+
+// inputs returns the files to read, stdin if none is given but piped, or
+// false for nothing to read.
+func inputs(fs files, args []string) (files, bool) {
+	if len(fs) == 0 && len(args) == 0 {
+		if !isPiped(os.Stdin) {
+			return nil, false
+		}
+		fs = append(fs, "-")
+	}
+	return fs, true
+}
+
+// scanLines calls `fn` with each line of `r` as it is read, failing on a line
+// longer than `maxLine`, and stopping at the first error of `fn`.
+func scanLines(r io.Reader, maxLine int, fn func(line string) error) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, min(maxLine, 64*1024)), maxLine)
+	for scanner.Scan() {
+		if err := fn(scanner.Text()); err != nil {
+			return err
+		}
+	}
+	err := scanner.Err()
+	if err == bufio.ErrTooLong {
+		err = fmt.Errorf("line over %d bytes: %w", maxLine, err)
+	}
+	return err
+}
+
+// openText opens the file of `path`, stdin if it is "-", decompressing it if
+// compressed, and decoding it as UTF-8. `done` closes it.
+func openText(path string) (br *bufio.Reader, done func(), err error) {
+	f := os.Stdin
+	if path != "-" {
+		if f, err = os.Open(path); err != nil {
+			return nil, nil, err
+		}
+	}
+	done = func() {
+		if f != os.Stdin {
+			f.Close()
+		}
+	}
+	r, err := decompress(f)
+	if err != nil {
+		done()
+		return nil, nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return decodeText(r), done, nil
+}
+
+// scanFile scans the text of the file of `path`, stdin if it is "-".
+func scanFile(path string, maxLine int, fn func(line string) error) error {
+	br, done, err := openText(path)
 	if err != nil {
-		fmt.Printf("%s\n", s)
-		fmt.Fprintf(os.Stderr, "error: %v\n", err)
-		return
+		return err
+	}
+	defer done()
+	return scanLines(br, maxLine, fn)
+}
+
+// output is where a command writes, stdout or a file replaced atomically.
+type output struct {
+	*bufio.Writer
+	af *atomicFile
+}
+
+func openOutput(path string) (*output, error) {
+	if len(path) == 0 {
+		return &output{Writer: bufio.NewWriter(os.Stdout)}, nil
 	}
-	fmt.Printf("%s %s\n", cc, ss)
-	for _, ipr := range pr {
-		fmt.Printf("%s\n", ipr)
+	af, err := createAtomic(path)
+	if err != nil {
+		return nil, err
 	}
+	return &output{bufio.NewWriter(af), af}, nil
+}
+
+// close flushes the output, and replaces the file with it if `ok`, or
+// discards it.
+func (o *output) close(ok bool) error {
+	err := o.Flush()
+	if o.af == nil {
+		return err
+	}
+	if err != nil || !ok {
+		o.af.Abort()
+		return err
+	}
+	return o.af.Commit()
 }
 
 func main_int() int {
-	var cc string
-	var file string
-
-	flag.Usage = func() {
-		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [-c char] [-f file]|[CIDR]|[IP1-IP2]\n", os.Args[0])
-		flag.PrintDefaults()
-	}
-	flag.StringVar(&file, "f", "", "input file path")
-	flag.StringVar(&cc, "c", "#", "comment character")
-	flag.Parse()
-
-	args := flag.Args()
-	if len(file) > 0 {
-		b, err := os.ReadFile(file)
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "%v\n", err)
-			return 1
-		}
-		x := len(b)
-		switch b[x-1] {
-		case '\n':
-			b = b[:x-1]
-			if b[x-2] == '\r' {
-				b = b[:x-2]
+	args := os.Args[1:]
+	if len(args) > 0 {
+		for _, c := range commands {
+			if args[0] == c.name {
+				return c.run(os.Args[0]+" "+c.name, args[1:])
 			}
-		case '\r':
-			b = b[:x-1]
-		}
-		lines := strings.Split(string(b), "\n")
-		for _, line := range lines {
-			processLine(strings.TrimSpace(line), cc)
 		}
-	} else if len(args) > 0 {
-		processLine(args[0], cc)
-	} else {
-		flag.Usage()
-		return 1
 	}
-	return 0
+	// expand without the command, as ever
+	return runExpand(os.Args[0], args)
 }
 
 func main() {
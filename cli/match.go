@@ -0,0 +1,149 @@
+// Copyright 2023-now by lifenjoiner. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE file.
+
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"net/netip"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/lifenjoiner/iprefix"
+)
+
+// entry is an entry read from a file.
+type entry struct {
+	text     string
+	file     string
+	line     int
+	prefixes []netip.Prefix
+}
+
+// contains reports whether the entry matches an IP, by the text for a
+// pattern.
+func (e *entry) contains(addr netip.Addr) bool {
+	if head, ok := strings.CutSuffix(e.text, "*"); ok {
+		return strings.HasPrefix(addr.String(), head)
+	}
+	for _, p := range e.prefixes {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// readEntries reads the entries of the files, skipping blank and comment
+// lines, and reports the bad ones, counted by `bad`. The ambiguous patterns,
+// see iprefix.NewPatternSet, are kept of no prefixes. They are bad unless
+// `ambiguous`, or the entries of all the files take them exactly together.
+func readEntries(paths []string, cs comments, maxLine int, ambiguous bool) (es []entry, bad int, err error) {
+	grouped := false
+	for _, path := range paths {
+		n := 0
+		err = scanFile(path, maxLine, func(line string) error {
+			n++
+			ss := cs.strip(line)
+			if len(ss) == 0 {
+				return nil
+			}
+			x := firstEntry(ss)
+			ps, err := entryPrefixes(x)
+			if errors.Is(err, iprefix.ErrAmbiguousPattern) {
+				grouped, err = true, nil
+			}
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "%s:%d: %v\n", path, n, err)
+				bad++
+				return nil
+			}
+			es = append(es, entry{x, path, n, ps})
+			return nil
+		})
+		if err != nil {
+			return
+		}
+	}
+	if grouped && !ambiguous {
+		var dropped int
+		es, dropped = dropAmbiguous(es)
+		bad += dropped
+	}
+	return
+}
+
+// dropAmbiguous drops and reports the ambiguous patterns that the entries
+// don't take exactly together, one by one as the groups change.
+func dropAmbiguous(es []entry) ([]entry, int) {
+	bad := 0
+	for {
+		_, err := iprefix.NewPatternSet(texts(es))
+		var ee *iprefix.EntryError
+		if !errors.As(err, &ee) || !errors.Is(err, iprefix.ErrAmbiguousPattern) {
+			return es, bad
+		}
+		e := es[ee.Line-1]
+		fmt.Fprintf(os.Stderr, "%s:%d: %v\n", e.file, e.line, ee.Err)
+		bad++
+		es = slices.Delete(es, ee.Line-1, ee.Line)
+	}
+}
+
+func runMatch(prog string, args []string) int {
+	var patternFiles files
+	cs := comments{"#"}
+	var maxLine int
+
+	fs := flag.NewFlagSet(prog, flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s [-c char] -f file... IP...\n"+
+			"Prints the entries of the files matching each IP, as `IP entry file:line`.\n"+
+			"Exits with 1 if any IP matches none, 2 on errors.\n", prog)
+		fs.PrintDefaults()
+	}
+	fs.Var(&patternFiles, "f", "pattern `file` path, - for stdin, repeatable")
+	fs.Var(&cs, "c", commentsUsage)
+	fs.IntVar(&maxLine, "maxline", 1024*1024, "max line length in bytes")
+	fs.Parse(args)
+
+	args = fs.Args()
+	if len(patternFiles) == 0 || len(args) == 0 {
+		fs.Usage()
+		return 2
+	}
+	addrs := make([]netip.Addr, len(args))
+	for i, arg := range args {
+		addr, err := netip.ParseAddr(arg)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			return 2
+		}
+		addrs[i] = addr
+	}
+	es, _, err := readEntries(patternFiles, cs, maxLine, true)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return 2
+	}
+
+	ret := 0
+	for _, addr := range addrs {
+		matched := false
+		for i := range es {
+			if es[i].contains(addr) {
+				fmt.Printf("%s %s %s:%d\n", addr, es[i].text, es[i].file, es[i].line)
+				matched = true
+			}
+		}
+		if !matched {
+			fmt.Fprintf(os.Stderr, "%s: no match\n", addr)
+			ret = 1
+		}
+	}
+	return ret
+}
@@ -0,0 +1,40 @@
+// Copyright 2023-now by lifenjoiner. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE file.
+
+package main
+
+import (
+	"net/netip"
+	"testing"
+)
+
+func TestEntryContains(t *testing.T) {
+	path := tempFile(t, "in.txt", "1111::4444:*\n1:0:0:4444::/64\n10.0.*\n")
+	if _, bad, err := readEntries([]string{path}, comments{"#"}, 1024, false); err != nil || bad != 1 {
+		t.Error(bad, err)
+	}
+	es, bad, err := readEntries([]string{path}, comments{"#"}, 1024, true)
+	if err != nil || bad != 0 || len(es) != 3 {
+		t.Fatal(es, bad, err)
+	}
+	tests := []struct {
+		addr     string
+		expected [3]bool
+	}{
+		{"1111::4444:0:1:2", [3]bool{true, false, false}},
+		{"1111::4444:1", [3]bool{true, false, false}},
+		{"1111:0:0:4444::", [3]bool{false, false, false}},
+		{"1:0:0:4444::1", [3]bool{false, true, false}},
+		{"10.0.1.2", [3]bool{false, false, true}},
+		{"::ffff:10.0.1.2", [3]bool{false, false, false}},
+	}
+	for _, mt := range tests {
+		addr := netip.MustParseAddr(mt.addr)
+		for i := range es {
+			if es[i].contains(addr) != mt.expected[i] {
+				t.Error(mt.addr, es[i].text)
+			}
+		}
+	}
+}
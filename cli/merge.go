@@ -0,0 +1,85 @@
+// Copyright 2023-now by lifenjoiner. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE file.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/lifenjoiner/iprefix"
+)
+
+func runMerge(prog string, args []string) int {
+	return aggregate(prog, args, false)
+}
+
+func runRevert(prog string, args []string) int {
+	return aggregate(prog, args, true)
+}
+
+// aggregate merges the entries into the least patterns, or CIDRs if `cidr`.
+func aggregate(prog string, args []string, cidr bool) int {
+	var inputFiles files
+	var outputFile string
+	cs := comments{"#"}
+	var maxLine int
+
+	fs := flag.NewFlagSet(prog, flag.ExitOnError)
+	fs.Usage = func() {
+		what := "patterns"
+		if cidr {
+			what = "CIDRs"
+		}
+		fmt.Fprintf(fs.Output(), "Usage: %s [-c char] [-o file] [-f file]...\n"+
+			"Merges the patterns, CIDRs, IP ranges and IPs of the files into the least\n"+
+			"%s covering the same IPs. Comments are dropped.\n", prog, what)
+		fs.PrintDefaults()
+	}
+	fs.Var(&inputFiles, "f", "input `file` path, - for stdin, repeatable")
+	fs.StringVar(&outputFile, "o", "", "output file path, replaced only when all done")
+	fs.Var(&cs, "c", commentsUsage)
+	fs.IntVar(&maxLine, "maxline", 1024*1024, "max line length in bytes")
+	fs.Parse(args)
+
+	inputFiles, ok := inputs(inputFiles, fs.Args())
+	if !ok || len(fs.Args()) > 0 {
+		fs.Usage()
+		return 1
+	}
+	es, bad, err := readEntries(inputFiles, cs, maxLine, false)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return 1
+	}
+	// all together, for the ambiguous patterns
+	var ps []string
+	if cidr {
+		ps, err = cidrs(texts(es))
+	} else {
+		ps, err = iprefix.ProcessMany(texts(es), iprefix.Minimize())
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return 1
+	}
+
+	out, err := openOutput(outputFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return 1
+	}
+	for _, p := range ps {
+		fmt.Fprintf(out, "%s\n", p)
+	}
+	if err := out.close(bad == 0); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return 1
+	}
+	if bad > 0 {
+		return 1
+	}
+	return 0
+}
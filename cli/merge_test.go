@@ -0,0 +1,68 @@
+// Copyright 2023-now by lifenjoiner. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE file.
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// expandFile writes the expansion of `in` without the entry lines.
+func expandFile(t *testing.T, in string) string {
+	t.Helper()
+	expanded := filepath.Join(t.TempDir(), "expanded.txt")
+	if ret := runExpand("cli", []string{"-no-echo", "-o", expanded, "-f", in}); ret != 0 {
+		t.Fatal(in, ret)
+	}
+	return expanded
+}
+
+func TestMergeExpansion(t *testing.T) {
+	tests := []struct {
+		in       string
+		expected string
+	}{
+		{"1111::/31", "1111:0:*\n1111::*\n1111:1:*\n"},
+		{"2001:db8::/33\n2001:db8:8000::/33", "2001:db8:*\n"},
+		{"10.0.0.0/23", "10.0.0.*\n10.0.1.*\n"},
+	}
+	for _, mt := range tests {
+		expanded := expandFile(t, tempFile(t, "in.txt", mt.in+"\n"))
+		out := filepath.Join(t.TempDir(), "out.txt")
+		if ret := runMerge("cli", []string{"-o", out, "-f", expanded}); ret != 0 {
+			t.Error(mt.in, ret)
+			continue
+		}
+		if b, err := os.ReadFile(out); err != nil || string(b) != mt.expected {
+			t.Errorf("%s: %q %v", mt.in, b, err)
+		}
+	}
+
+	// only the ambiguous patterns not taken together are bad
+	in := tempFile(t, "in.txt", "1111:0:*\n1111::*\n1111::\n1111:1:*\n1::4444:*\n")
+	out := filepath.Join(t.TempDir(), "out.txt")
+	if ret := runMerge("cli", []string{"-o", out, "-f", in}); ret != 1 {
+		t.Error(ret)
+	}
+	es, bad, err := readEntries([]string{in}, comments{"#"}, 1024, false)
+	if err != nil || bad != 1 || len(es) != 4 || es[3].text != "1111:1:*" {
+		t.Error(es, bad, err)
+	}
+}
+
+func TestRevertExpansion(t *testing.T) {
+	for _, s := range []string{"1111::/31", "2001:db8::/33", "10.0.0.0/23"} {
+		expanded := expandFile(t, tempFile(t, "in.txt", s+"\n"))
+		out := filepath.Join(t.TempDir(), "out.txt")
+		if ret := runRevert("cli", []string{"-o", out, "-f", expanded}); ret != 0 {
+			t.Error(s, ret)
+			continue
+		}
+		if b, err := os.ReadFile(out); err != nil || string(b) != s+"\n" {
+			t.Errorf("%s: %q %v", s, b, err)
+		}
+	}
+}
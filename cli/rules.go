@@ -0,0 +1,125 @@
+// Copyright 2023-now by lifenjoiner. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE file.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/lifenjoiner/iprefix"
+)
+
+// rulesKinds are the dnscrypt-proxy rule files of names and their IPs:
+// cloaking-rules of a name and an IP per line, and forwarding-rules of a name
+// and the servers separated by commas.
+var rulesKinds = []string{"cloaking", "forwarding"}
+
+// maxRuleIPs caps the IPs a range of a rule expands to.
+const maxRuleIPs = 4096
+
+// checkRules checks the flags fit the rules mode.
+func (pp *processor) checkRules() error {
+	switch pp.rules {
+	case "":
+		return nil
+	case "cloaking", "forwarding":
+		if pp.format != "plain" || pp.csvIn || pp.ndjson || pp.sort || len(pp.target) > 0 {
+			return errors.New("-rules takes the plain format, and no -csv, -ndjson, -sort or -target")
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown rules: %s, not one of %s", pp.rules, strings.Join(rulesKinds, ", "))
+}
+
+// ruleIPs returns the IPs of a CIDR or an IP range, or false if `x` is not
+// one, like a host name.
+func ruleIPs(x string) (ips []string, ok bool, err error) {
+	if !strings.ContainsAny(x, "/-") {
+		return nil, false, nil
+	}
+	ps, err := entryPrefixes(x)
+	if err != nil {
+		return nil, false, nil
+	}
+	n, err := iprefix.Count([]string{x})
+	if err != nil {
+		return nil, true, err
+	}
+	if !n.IsInt64() || n.Int64() > maxRuleIPs {
+		return nil, true, fmt.Errorf("%s: over %d IPs", x, maxRuleIPs)
+	}
+	for _, p := range ps {
+		for addr := p.Addr(); addr.IsValid() && p.Contains(addr); addr = addr.Next() {
+			ips = append(ips, addr.String())
+		}
+	}
+	return ips, true, nil
+}
+
+// ruleLine expands the CIDRs and IP ranges of the IPs of a rule line, keeping
+// the name, the spacing and the other fields.
+func (pp *processor) ruleLine(s, ss, content string) error {
+	f := strings.Fields(content)
+	if len(f) < 2 {
+		pp.pass(s)
+		return nil
+	}
+	i := len(f[0]) + strings.Index(content[len(f[0]):], f[1])
+	head, tail := content[:i], content[i+len(f[1]):]
+
+	var lines []string
+	if pp.rules == "cloaking" {
+		ips, ok, err := ruleIPs(f[1])
+		if err != nil || !ok {
+			return pp.ruleDone(s, f[1], err)
+		}
+		for _, ip := range ips {
+			lines = append(lines, head+ip+tail)
+		}
+	} else {
+		var servers []string
+		expanded := false
+		for _, x := range strings.Split(f[1], ",") {
+			ips, ok, err := ruleIPs(x)
+			if err != nil {
+				return pp.ruleDone(s, x, err)
+			}
+			if ok {
+				servers, expanded = append(servers, ips...), true
+			} else {
+				servers = append(servers, x)
+			}
+		}
+		if !expanded {
+			return pp.ruleDone(s, f[1], nil)
+		}
+		lines = append(lines, head+strings.Join(servers, ",")+tail)
+	}
+	if pp.keep {
+		fmt.Fprintf(pp.w, "%s\n", ss)
+	} else if !pp.noEcho {
+		fmt.Fprintf(pp.w, "%s %s\n", pp.mark(), ss)
+	}
+	for _, line := range lines {
+		fmt.Fprintf(pp.w, "%s\n", line)
+	}
+	pp.trace("%s: %d lines", f[1], len(lines))
+	return nil
+}
+
+// ruleDone passes a rule line not expanded, failing on `err` in strict mode.
+func (pp *processor) ruleDone(s, x string, err error) error {
+	if err != nil {
+		if pp.strict {
+			return pp.wrap(err)
+		}
+		pp.warn(err)
+	} else {
+		pp.trace("%s: passed", x)
+	}
+	pp.pass(s)
+	return nil
+}
@@ -0,0 +1,175 @@
+// Copyright 2023-now by lifenjoiner. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE file.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"math/big"
+	"net/netip"
+	"os"
+	"slices"
+
+	"github.com/lifenjoiner/iprefix"
+)
+
+// stats is the statistics of the entries of a file.
+type stats struct {
+	entries, invalid, patterns int
+	// the addresses covered by family, overlaps counted once
+	v4, v6 *big.Int
+	// the entries overlapping others, and the addresses covered more than once
+	overlapping int
+	overlapped  *big.Int
+	// the entries by count of addresses, descending
+	largest []sized
+}
+
+type sized struct {
+	*entry
+	count *big.Int
+}
+
+// entryStats takes the entries as their union `set` does, the ambiguous
+// patterns by the IPs they match by text.
+func entryStats(es []entry, set *iprefix.PatternSet, bad, top int) *stats {
+	st := &stats{entries: len(es), invalid: bad, v4: new(big.Int), v6: new(big.Int), overlapped: new(big.Int)}
+	for _, p := range set.Prefixes() {
+		if p.Addr().Is4() {
+			st.v4.Add(st.v4, iprefix.PrefixCount(p))
+		} else {
+			st.v6.Add(st.v6, iprefix.PrefixCount(p))
+		}
+	}
+
+	// an entry spans from its first to its last IP, contiguous unless an
+	// ambiguous pattern
+	type span struct {
+		from, to netip.Addr
+		e        *entry
+	}
+	spans := make([]span, len(es))
+	sum := new(big.Int)
+	for i := range es {
+		e := &es[i]
+		s := &spans[i]
+		s.e = e
+		var n *big.Int
+		if e.prefixes == nil {
+			var r iprefix.Range
+			r, n, _ = iprefix.PatternTextRange(e.text)
+			st.patterns++
+			s.from, s.to = r.Start, r.End
+		} else {
+			n = new(big.Int)
+			for _, p := range e.prefixes {
+				st.patterns += iprefix.PatternCount(p)
+				n.Add(n, iprefix.PrefixCount(p))
+			}
+			s.from, _ = iprefix.PrefixToRange(e.prefixes[0])
+			_, s.to = iprefix.PrefixToRange(e.prefixes[len(e.prefixes)-1])
+		}
+		sum.Add(sum, n)
+		st.largest = append(st.largest, sized{e, n})
+	}
+	st.overlapped.Sub(sum, st.v4)
+	st.overlapped.Sub(st.overlapped, st.v6)
+
+	// sweep the spans by start, checking by text the ones of ambiguous
+	// patterns
+	slices.SortFunc(spans, func(a, b span) int {
+		return a.from.Compare(b.from)
+	})
+	overlapping := make([]bool, len(spans))
+	var active []int
+	for i, s := range spans {
+		active = slices.DeleteFunc(active, func(j int) bool {
+			return spans[j].to.Less(s.from)
+		})
+		for _, j := range active {
+			o := spans[j].e
+			if o.prefixes == nil || s.e.prefixes == nil {
+				if ok, _ := iprefix.OverlapPatternTexts(o.text, s.e.text); !ok {
+					continue
+				}
+			}
+			overlapping[i], overlapping[j] = true, true
+		}
+		active = append(active, i)
+	}
+	for _, ok := range overlapping {
+		if ok {
+			st.overlapping++
+		}
+	}
+
+	slices.SortStableFunc(st.largest, func(a, b sized) int {
+		return b.count.Cmp(a.count)
+	})
+	st.largest = st.largest[:min(top, len(st.largest))]
+	return st
+}
+
+func (st *stats) print(w io.Writer, name string) {
+	fmt.Fprintf(w, "%s:\n", name)
+	fmt.Fprintf(w, "  entries:         %d\n", st.entries)
+	fmt.Fprintf(w, "  invalid:         %d\n", st.invalid)
+	fmt.Fprintf(w, "  patterns:        %d\n", st.patterns)
+	fmt.Fprintf(w, "  IPv4 addresses:  %s\n", st.v4)
+	fmt.Fprintf(w, "  IPv6 addresses:  %s\n", st.v6)
+	fmt.Fprintf(w, "  overlapping:     %d entries, %s addresses\n", st.overlapping, st.overlapped)
+	if len(st.largest) > 0 {
+		fmt.Fprintf(w, "  largest:\n")
+		for _, s := range st.largest {
+			fmt.Fprintf(w, "    %s\t%s\tline %d\n", s.text, s.count, s.line)
+		}
+	}
+}
+
+func runStats(prog string, args []string) int {
+	var inputFiles files
+	cs := comments{"#"}
+	var maxLine, top int
+
+	fs := flag.NewFlagSet(prog, flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s [-c char] [-top n] [-f file]... [file]...\n"+
+			"Reports the entries, patterns, addresses by family, overlaps and the\n"+
+			"largest entries of each file.\n", prog)
+		fs.PrintDefaults()
+	}
+	fs.Var(&inputFiles, "f", "input `file` path, - for stdin, repeatable")
+	fs.Var(&cs, "c", commentsUsage)
+	fs.IntVar(&maxLine, "maxline", 1024*1024, "max line length in bytes")
+	fs.IntVar(&top, "top", 5, "count of the largest entries to list")
+	fs.Parse(args)
+
+	inputFiles = append(inputFiles, fs.Args()...)
+	inputFiles, ok := inputs(inputFiles, nil)
+	if !ok {
+		fs.Usage()
+		return 1
+	}
+	ret := 0
+	for _, file := range inputFiles {
+		es, bad, err := readEntries([]string{file}, cs, maxLine, false)
+		var set *iprefix.PatternSet
+		if err == nil {
+			set, err = iprefix.NewPatternSet(texts(es))
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			ret = 1
+			continue
+		}
+		name := file
+		if name == "-" {
+			name = "stdin"
+		}
+		entryStats(es, set, bad, top).print(os.Stdout, name)
+	}
+	return ret
+}
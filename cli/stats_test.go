@@ -0,0 +1,41 @@
+// Copyright 2023-now by lifenjoiner. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE file.
+
+package main
+
+import (
+	"testing"
+
+	"github.com/lifenjoiner/iprefix"
+)
+
+func TestEntryStats(t *testing.T) {
+	tests := []struct {
+		in                    string
+		entries, invalid      int
+		patterns, overlapping int
+		v4, v6, overlapped    string
+	}{
+		{"10.0.0.0/23\n10.0.1.*\nx\n", 2, 1, 3, 2, "512", "0", "256"},
+		{"1111:0:*\n1111::*\n1111:1:*\n", 3, 0, 3, 0, "0", "158456325028528675187087900672", "0"},
+		{"1111:0:*\n1111::*\n1111::\n1111:1:*\n", 4, 0, 4, 2, "0", "158456325028528675187087900672", "1"},
+		{"1111::*\n", 0, 1, 0, 0, "0", "0", "0"},
+	}
+	for _, mt := range tests {
+		es, bad, err := readEntries([]string{tempFile(t, "in.txt", mt.in)}, comments{"#"}, 1024, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		set, err := iprefix.NewPatternSet(texts(es))
+		if err != nil {
+			t.Fatal(err)
+		}
+		st := entryStats(es, set, bad, 1)
+		if st.entries != mt.entries || st.invalid != mt.invalid || st.patterns != mt.patterns ||
+			st.overlapping != mt.overlapping || st.v4.String() != mt.v4 || st.v6.String() != mt.v6 ||
+			st.overlapped.String() != mt.overlapped {
+			t.Errorf("%q: %+v", mt.in, st)
+		}
+	}
+}
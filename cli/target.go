@@ -0,0 +1,101 @@
+// Copyright 2023-now by lifenjoiner. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE file.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+	"strings"
+
+	"github.com/lifenjoiner/iprefix"
+)
+
+// targets are the applications the output is checked for, "" for none.
+var targets = []string{"dnscrypt"}
+
+// checkTarget checks the flags fit the target, and tunes the options for it.
+func (pp *processor) checkTarget() error {
+	switch pp.target {
+	case "":
+		return nil
+	case "dnscrypt":
+		if pp.format != "plain" || pp.wildcard != "*" || pp.keep {
+			return errors.New("-target dnscrypt takes the plain format, the wildcard * and no -keep")
+		}
+		// dnscrypt-proxy matches the IPv4 answers of AAAA queries as IPv4
+		pp.opts = append(pp.opts, iprefix.MapIPv4(iprefix.IPv4Unmapped))
+		return nil
+	}
+	return fmt.Errorf("unknown target: %s, not one of %s", pp.target, strings.Join(targets, ", "))
+}
+
+// mark returns the mark commenting out lines.
+func (pp *processor) mark() string {
+	if pp.target == "dnscrypt" {
+		return "#"
+	}
+	if m, ok := marks[pp.format]; ok {
+		return m
+	}
+	return pp.cs.mark()
+}
+
+// checkLine checks an output line is valid for the target, or the format of
+// nginx.
+func (pp *processor) checkLine(s string) error {
+	switch {
+	case pp.target == "dnscrypt":
+		return checkDNSCrypt(s)
+	case strings.HasPrefix(pp.format, "nginx"):
+		return checkNginx(s)
+	}
+	return nil
+}
+
+// checkNginx checks a line is a blank, comment or simple directive line of an
+// nginx config.
+func checkNginx(s string) error {
+	s = strings.TrimSpace(s)
+	if len(s) == 0 || s[0] == '#' || strings.HasSuffix(s, ";") {
+		return nil
+	}
+	return fmt.Errorf("not an nginx directive: %s", s)
+}
+
+// checkDNSCrypt checks a line is a blank, comment or rule line of the
+// dnscrypt-proxy blocked-ips file: an IP in the text dnscrypt-proxy compares,
+// or a prefix of it ended by the wildcard `*`, optionally followed by an
+// inline comment after a space.
+func checkDNSCrypt(s string) error {
+	rule := strings.TrimSpace(s)
+	if i := strings.LastIndexByte(rule, '#'); i >= 0 {
+		if rule[0] == '#' {
+			return nil
+		}
+		if c := rule[i-1]; c == ' ' || c == '\t' {
+			rule = strings.TrimSpace(rule[:i-1])
+		}
+	}
+	if len(rule) == 0 {
+		return nil
+	}
+	head, star := strings.CutSuffix(rule, "*")
+	if star {
+		// dnscrypt-proxy drops the separator ending the prefix
+		if strings.ContainsAny(head, "* \t#") || len(strings.TrimRight(head, ".:")) == 0 {
+			return fmt.Errorf("not a dnscrypt rule: %s", rule)
+		}
+		return nil
+	}
+	addr, err := netip.ParseAddr(head)
+	if err != nil {
+		return fmt.Errorf("not a dnscrypt rule: %s", rule)
+	}
+	if text := addr.Unmap().String(); text != head {
+		return fmt.Errorf("dnscrypt never matches %s, but %s", head, text)
+	}
+	return nil
+}
@@ -0,0 +1,158 @@
+// Copyright 2023-now by lifenjoiner. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+// walkFiles replaces the directories of `paths` with their regular files,
+// walked in lexical order.
+func walkFiles(paths files) (files, error) {
+	var ps files
+	for _, path := range paths {
+		if path == "-" {
+			ps = append(ps, path)
+			continue
+		}
+		fi, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		if !fi.IsDir() {
+			ps = append(ps, path)
+			continue
+		}
+		err = filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+			if err == nil && d.Type().IsRegular() {
+				ps = append(ps, p)
+			}
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return ps, nil
+}
+
+// work calls `fn` with each index of [0, n) by `j` workers, all CPUs if not
+// positive, and calls `done` with the indexes in order as they are finished,
+// stopping when `done` returns false.
+func work(n, j int, fn func(i int), done func(i int) bool) {
+	if j <= 0 {
+		j = runtime.NumCPU()
+	}
+	ready := make([]chan struct{}, n)
+	for i := range ready {
+		ready[i] = make(chan struct{})
+	}
+	var next atomic.Int64
+	var stop atomic.Bool
+	var wg sync.WaitGroup
+	for range min(j, n) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for !stop.Load() {
+				i := int(next.Add(1) - 1)
+				if i >= n {
+					return
+				}
+				fn(i)
+				close(ready[i])
+			}
+		}()
+	}
+	for i := range n {
+		<-ready[i]
+		if !done(i) {
+			stop.Store(true)
+			break
+		}
+	}
+	wg.Wait()
+}
+
+// files processes the files by `j` workers, each file into a buffer of its
+// own, written to `w` in order, and returns whether all succeeded.
+func (pp *processor) files(paths []string, j int) bool {
+	ok := true
+	if j == 1 {
+		for _, path := range paths {
+			if err := pp.file(path); err != nil {
+				fmt.Fprintf(os.Stderr, "%v\n", err)
+				ok = false
+				if pp.strict {
+					break
+				}
+			}
+		}
+		return ok
+	}
+
+	bufs := make([]bytes.Buffer, len(paths))
+	errs := make([]error, len(paths))
+	helds := make([][]held, len(paths))
+	crlfs := make([]bool, len(paths))
+	// the workers copy it, as the writing changes pp
+	tp := *pp
+	work(len(paths), j, func(i int) {
+		wp := tp
+		wp.w, wp.held, wp.ew = bufio.NewWriter(&bufs[i]), nil, nil
+		if wp.format == "csv" {
+			// the header is written to w, before the first record
+			wp.csv = csv.NewWriter(wp.w)
+		}
+		if errs[i] = wp.file(paths[i]); errs[i] == nil {
+			errs[i] = wp.w.Flush()
+		}
+		helds[i], crlfs[i] = wp.held, wp.crlf
+	}, func(i int) bool {
+		pp.ew.follow(crlfs[i])
+		pp.held = append(pp.held, helds[i]...)
+		helds[i] = nil
+		if pp.format == "csv" && bufs[i].Len() > 0 {
+			pp.csvWriter().Flush()
+		}
+		if _, err := bufs[i].WriteTo(pp.w); err != nil && errs[i] == nil {
+			errs[i] = err
+		}
+		bufs[i] = bytes.Buffer{}
+		if errs[i] != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", errs[i])
+			ok = false
+		}
+		return ok || !pp.strict
+	})
+	return ok
+}
+
+// editFiles edits the files in place by `j` workers, and returns whether all
+// succeeded.
+func (pp *processor) editFiles(paths []string, suffix string, j int) bool {
+	errs := make([]error, len(paths))
+	ok := true
+	work(len(paths), j, func(i int) {
+		wp := *pp
+		errs[i] = wp.editFile(paths[i], suffix)
+	}, func(i int) bool {
+		if errs[i] != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", errs[i])
+			ok = false
+		}
+		return ok || !pp.strict
+	})
+	return ok
+}
@@ -0,0 +1,121 @@
+// Copyright 2023-now by lifenjoiner. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE file.
+
+package iprefix
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net/netip"
+	"sort"
+)
+
+// The compiled set format, all integers big-endian:
+//
+//	magic "IPFX", version uint32, n4 uint32, n6 uint32,
+//	n4 IPv4 ranges of 4 bytes start and end,
+//	n6 IPv6 ranges of 16 bytes start and end.
+//
+// The ranges are sorted and merged, and compared as bytes in place, so the
+// data can be memory-mapped and shared without decoding.
+const (
+	compiledMagic   = "IPFX"
+	compiledVersion = 1
+	compiledHeader  = 16
+)
+
+// WriteCompiled writes the set in the compiled set format.
+func (set *PatternSet) WriteCompiled(w io.Writer) error {
+	var n4, n6 int
+	for _, r := range set.ranges {
+		if r.from.Is4() {
+			n4++
+		} else {
+			n6++
+		}
+	}
+	b := make([]byte, 0, compiledHeader+8*n4+32*n6)
+	b = append(b, compiledMagic...)
+	b = binary.BigEndian.AppendUint32(b, compiledVersion)
+	b = binary.BigEndian.AppendUint32(b, uint32(n4))
+	b = binary.BigEndian.AppendUint32(b, uint32(n6))
+	for _, r := range set.ranges {
+		b = append(append(b, r.from.AsSlice()...), r.to.AsSlice()...)
+	}
+	_, err := w.Write(b)
+	return err
+}
+
+// CompiledSet is a set queried in place of the data in the compiled set
+// format.
+type CompiledSet struct {
+	v4, v6 []byte
+	data   []byte
+	unmap  func([]byte) error
+}
+
+// LoadCompiled returns the CompiledSet of the data, which must not be modified
+// while it is in use.
+func LoadCompiled(data []byte) (*CompiledSet, error) {
+	if len(data) < compiledHeader || string(data[:4]) != compiledMagic ||
+		binary.BigEndian.Uint32(data[4:]) != compiledVersion {
+		return nil, fmt.Errorf("%w: bad header", ErrInvalidCompiled)
+	}
+	n4 := uint64(binary.BigEndian.Uint32(data[8:]))
+	n6 := uint64(binary.BigEndian.Uint32(data[12:]))
+	if uint64(len(data)) != compiledHeader+8*n4+32*n6 {
+		return nil, fmt.Errorf("%w: bad size %d", ErrInvalidCompiled, len(data))
+	}
+	x := compiledHeader + 8*n4
+	return &CompiledSet{v4: data[compiledHeader:x], v6: data[x:], data: data}, nil
+}
+
+// Contains reports whether `addr` is in the set.
+func (c *CompiledSet) Contains(addr netip.Addr) bool {
+	if !addr.IsValid() {
+		return false
+	}
+	ranges := c.v6
+	if addr.Is4() {
+		ranges = c.v4
+	}
+	ip := addr.AsSlice()
+	size := 2 * len(ip)
+	n := len(ranges) / size
+	// the first range ending not before `ip`
+	i := sort.Search(n, func(i int) bool {
+		to := ranges[i*size+len(ip) : (i+1)*size]
+		return bytes.Compare(to, ip) >= 0
+	})
+	return i < n && bytes.Compare(ranges[i*size:i*size+len(ip)], ip) <= 0
+}
+
+// Close releases the memory-mapped data of OpenCompiled.
+func (c *CompiledSet) Close() (err error) {
+	if c.unmap != nil {
+		err = c.unmap(c.data)
+		c.unmap, c.v4, c.v6, c.data = nil, nil, nil, nil
+	}
+	return
+}
+
+// OpenCompiled opens the file in the compiled set format, memory-mapped where
+// supported, so many processes share one copy. Close it after use.
+func OpenCompiled(path string) (*CompiledSet, error) {
+	data, unmap, err := mapFile(path)
+	if err != nil {
+		return nil, err
+	}
+	c, err := LoadCompiled(data)
+	if err != nil {
+		if unmap != nil {
+			unmap(data)
+		}
+		return nil, err
+	}
+	c.unmap = unmap
+	return c, nil
+}
@@ -0,0 +1,15 @@
+// Copyright 2023-now by lifenjoiner. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE file.
+
+//go:build !(linux || darwin || freebsd || netbsd || openbsd || dragonfly)
+
+package iprefix
+
+import "os"
+
+// mapFile reads the file into memory, where memory mapping isn't supported.
+func mapFile(path string) (data []byte, unmap func([]byte) error, err error) {
+	data, err = os.ReadFile(path)
+	return
+}
@@ -0,0 +1,77 @@
+// Copyright 2023-now by lifenjoiner. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE file.
+
+package iprefix
+
+import (
+	"bytes"
+	"errors"
+	"math/rand"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCompiledSet(t *testing.T) {
+	set, err := NewPatternSet([]string{"10.*", "192.168.1.0/24", "172.16.0.1-172.16.0.9", "::ffff:10.1.*", "2001:20:*", "8.8.8.8", "255.255.255.255"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "set.ipfx")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = set.WriteCompiled(f); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	c, err := OpenCompiled(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	for _, s := range []string{"10.0.0.0", "10.255.255.255", "172.16.0.1", "172.16.0.9", "8.8.8.8", "255.255.255.255", "::ffff:10.1.0.1", "2001:20::"} {
+		if !c.Contains(netip.MustParseAddr(s)) {
+			t.Error(s)
+		}
+	}
+	for _, s := range []string{"9.255.255.255", "11.0.0.0", "172.16.0.0", "172.16.0.10", "::ffff:10.2.0.1", "2001:21::", "::"} {
+		if c.Contains(netip.MustParseAddr(s)) {
+			t.Error(s)
+		}
+	}
+	if c.Contains(netip.Addr{}) {
+		t.Error("zero Addr")
+	}
+	rnd := rand.New(rand.NewSource(1))
+	for i := 0; i < 10000; i++ {
+		addr := netip.AddrFrom4([4]byte{byte(rnd.Intn(256)), byte(rnd.Intn(256)), byte(rnd.Intn(256)), byte(rnd.Intn(256))})
+		if c.Contains(addr) != set.Contains(addr) {
+			t.Error(addr)
+		}
+	}
+	if err = c.Close(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestLoadCompiled(t *testing.T) {
+	var b bytes.Buffer
+	(&PatternSet{}).WriteCompiled(&b)
+	c, err := LoadCompiled(b.Bytes())
+	if err != nil || c.Contains(netip.MustParseAddr("1.2.3.4")) {
+		t.Error(err)
+	}
+	for _, data := range [][]byte{nil, []byte("IPFX"), append(b.Bytes(), 0), []byte("XPFX\x00\x00\x00\x01\x00\x00\x00\x00\x00\x00\x00\x00")} {
+		if _, err = LoadCompiled(data); !errors.Is(err, ErrInvalidCompiled) {
+			t.Error(data, err)
+		}
+	}
+	if _, err = OpenCompiled(filepath.Join(t.TempDir(), "none")); err == nil {
+		t.Error("none")
+	}
+}
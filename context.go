@@ -0,0 +1,81 @@
+// Copyright 2023-now by lifenjoiner. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE file.
+
+package iprefix
+
+import "context"
+
+// checkInterval is the count of patterns between checks of the context.
+const checkInterval = 1024
+
+// collectContext collects the patterns of the generator until `ctx` is done.
+func collectContext(ctx context.Context, gen func(yield func(string) bool) bool) (ps []string, err error) {
+	if err = ctx.Err(); err != nil {
+		return
+	}
+	gen(func(p string) bool {
+		ps = append(ps, p)
+		if len(ps)%checkInterval == 0 {
+			err = ctx.Err()
+		}
+		return err == nil
+	})
+	if err != nil {
+		ps = nil
+	}
+	return
+}
+
+// ProcessCIDRContext generates string IP prefix pattern from CIDR, aborting
+// with the error of `ctx` when it is done.
+func ProcessCIDRContext(ctx context.Context, s string) (ps []string, err error) {
+	p, err := parsePrefix(s)
+	if err != nil {
+		return
+	}
+	return collectContext(ctx, func(yield func(string) bool) bool {
+		return processPrefix(p, IPv6All, yield)
+	})
+}
+
+// ProcessRangeContext generates string IP prefix pattern from IP range,
+// aborting with the error of `ctx` when it is done.
+// `s` is start IP. `e` is end IP.
+func ProcessRangeContext(ctx context.Context, s, e string) (ps []string, err error) {
+	addr1, addr2, err := parseRange(s, e)
+	if err != nil {
+		return
+	}
+	return collectContext(ctx, func(yield func(string) bool) bool {
+		return processRange(addr1, addr2, IPv6All, yield)
+	})
+}
+
+// ProcessContext is Process aborting with the error of `ctx` when it is done.
+func ProcessContext(ctx context.Context, s string, opts ...Option) (ps []string, err error) {
+	o := newOptions(opts)
+	gen, err := o.generator(s)
+	if err != nil {
+		return
+	}
+	var n int
+	var ctxErr error
+	ps, err = o.output(func(yield func(string) bool) bool {
+		if ctxErr = ctx.Err(); ctxErr != nil {
+			return false
+		}
+		return gen(func(p string) bool {
+			if n++; n%checkInterval == 0 {
+				if ctxErr = ctx.Err(); ctxErr != nil {
+					return false
+				}
+			}
+			return yield(p)
+		})
+	})
+	if ctxErr != nil {
+		return nil, ctxErr
+	}
+	return
+}
@@ -0,0 +1,50 @@
+// Copyright 2023-now by lifenjoiner. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE file.
+
+package iprefix
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestProcessContext(t *testing.T) {
+	ctx := context.Background()
+	ps, err := ProcessCIDRContext(ctx, "10.0.0.0/23")
+	if err != nil || !validate(ps, []string{"10.0.0.*", "10.0.1.*"}) {
+		t.Error(ps, err)
+	}
+	ps, err = ProcessRangeContext(ctx, "10.0.0.0", "10.0.1.255")
+	if err != nil || !validate(ps, []string{"10.0.0.*", "10.0.1.*"}) {
+		t.Error(ps, err)
+	}
+	ps, err = ProcessContext(ctx, "10.0.0.0/23", Limit(1))
+	if err != nil || len(ps) != 1 {
+		t.Error(ps, err)
+	}
+	if _, err = ProcessCIDRContext(ctx, "x"); err == nil {
+		t.Error("x")
+	}
+
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	if ps, err = ProcessCIDRContext(canceled, "10.0.0.0/23"); !errors.Is(err, context.Canceled) || ps != nil {
+		t.Error(ps, err)
+	}
+	if ps, err = ProcessContext(canceled, "10.0.0.0/23"); !errors.Is(err, context.Canceled) || ps != nil {
+		t.Error(ps, err)
+	}
+
+	timeout, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, err = ProcessRangeContext(timeout, "1::1", "ffff:fffe:ffff:ffff:ffff:ffff:ffff:fffe"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Error(err)
+	}
+	if time.Since(start) > time.Second {
+		t.Error(time.Since(start))
+	}
+}
@@ -0,0 +1,49 @@
+// Copyright 2023-now by lifenjoiner. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE file.
+
+package iprefix
+
+import (
+	"bufio"
+	"io"
+	"strings"
+)
+
+// Decoder reads Patterns from a pattern file line by line. The pattern of a
+// line is its first field, the trailing ones are comments. Blank lines and the
+// ones starting with `#` are skipped.
+type Decoder struct {
+	s    *bufio.Scanner
+	line int
+}
+
+// NewDecoder returns a Decoder reading from `r`.
+func NewDecoder(r io.Reader) *Decoder {
+	return &Decoder{s: bufio.NewScanner(r)}
+}
+
+// Decode returns the next Pattern, or io.EOF at the end. An invalid pattern
+// fails with an EntryError, and the decoding can go on.
+func (dec *Decoder) Decode() (pt Pattern, err error) {
+	for dec.s.Scan() {
+		dec.line++
+		s := lineEntry(dec.s.Text())
+		if s == "" {
+			continue
+		}
+		p, err := ParsePattern(s)
+		if err != nil {
+			return pt, &EntryError{s, dec.line, err}
+		}
+		family := 6
+		if p.Addr().Is4() {
+			family = 4
+		}
+		return Pattern{s, p, family, strings.HasSuffix(s, "*")}, nil
+	}
+	if err = dec.s.Err(); err == nil {
+		err = io.EOF
+	}
+	return
+}
@@ -0,0 +1,41 @@
+// Copyright 2023-now by lifenjoiner. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE file.
+
+package iprefix
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestDecoder(t *testing.T) {
+	in := "# 10.0.0.0/23\n10.0.0.*\n10.0.1.* office\n\n  ::1\nx\r\n2001:db8:*\r\n"
+	dec := NewDecoder(strings.NewReader(in))
+	var texts []string
+	for {
+		pt, err := dec.Decode()
+		if err == io.EOF {
+			break
+		}
+		var ee *EntryError
+		if err != nil {
+			if !errors.As(err, &ee) || ee.Line != 6 || ee.Input != "x" {
+				t.Error(err)
+			}
+			continue
+		}
+		texts = append(texts, pt.Text)
+		if pt.Text == "::1" && (pt.Family != 6 || pt.Wildcard || pt.Prefix.Bits() != 128) {
+			t.Error(pt)
+		}
+		if pt.Text == "10.0.1.*" && (pt.Family != 4 || !pt.Wildcard || pt.Prefix.String() != "10.0.1.0/24") {
+			t.Error(pt)
+		}
+	}
+	if strings.Join(texts, ",") != "10.0.0.*,10.0.1.*,::1,2001:db8:*" {
+		t.Error(texts)
+	}
+}
@@ -0,0 +1,78 @@
+// Copyright 2023-now by lifenjoiner. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE file.
+
+package iprefix
+
+import "net/netip"
+
+// NAT64WellKnown is the NAT64 well-known prefix of RFC 6052.
+var NAT64WellKnown = netip.MustParsePrefix("64:ff9b::/96")
+
+// NAT64IPv4 returns the IPv4 embedded in `addr` of the NAT64 /96 `prefix`.
+func NAT64IPv4(addr netip.Addr, prefix netip.Prefix) (netip.Addr, bool) {
+	if prefix.Bits() != 96 || !addr.Is6() || !prefix.Contains(addr) {
+		return netip.Addr{}, false
+	}
+	return embedded4(addr, 96), true
+}
+
+// SixToFour is the 6to4 prefix of RFC 3056.
+var SixToFour = netip.MustParsePrefix("2002::/16")
+
+// SixToFourIPv4 returns the IPv4 embedded in a 6to4 `addr`.
+func SixToFourIPv4(addr netip.Addr) (netip.Addr, bool) {
+	if !SixToFour.Contains(addr) {
+		return netip.Addr{}, false
+	}
+	return embedded4(addr, 16), true
+}
+
+// Teredo is the Teredo prefix of RFC 4380.
+var Teredo = netip.MustParsePrefix("2001::/32")
+
+// TeredoIPv4 returns the server IPv4 and the deobfuscated client IPv4
+// embedded in a Teredo `addr`. The client IPv4s of a Teredo prefix are not
+// contiguous but scattered by the obfuscation, so they only decode per address.
+func TeredoIPv4(addr netip.Addr) (server, client netip.Addr, ok bool) {
+	if !Teredo.Contains(addr) {
+		return
+	}
+	ip := addr.As16()
+	for i := 12; i < 16; i++ {
+		ip[i] ^= 0xff
+	}
+	return embedded4(addr, 32), netip.AddrFrom4([4]byte(ip[12:])), true
+}
+
+// embedded4 returns the IPv4 of the 32 bits of an IPv6 address from the
+// octet aligned `offset`.
+func embedded4(addr netip.Addr, offset int) netip.Addr {
+	ip := addr.As16()
+	return netip.AddrFrom4([4]byte(ip[offset/8:]))
+}
+
+// embedding is the IPv6 prefix with IPv4 embedded from the `offset` bit right
+// after it, so its ranges map to contiguous IPv4 ones.
+type embedding struct {
+	addrRange
+	offset    int
+	alongside bool
+}
+
+// ranges converts the parts of merged ranges in the prefix to the embedded
+// IPv4 ones.
+func (e embedding) ranges(rs []addrRange) []addrRange {
+	x := intersectRanges(rs, []addrRange{e.addrRange})
+	if len(x) == 0 {
+		return rs
+	}
+	if !e.alongside {
+		rs = subtractRanges(rs, x)
+	}
+	rs = rs[:len(rs):len(rs)]
+	for _, r := range x {
+		rs = append(rs, addrRange{embedded4(r.from, e.offset), embedded4(r.to, e.offset)})
+	}
+	return mergeRanges(rs)
+}
@@ -0,0 +1,87 @@
+// Copyright 2023-now by lifenjoiner. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE file.
+
+package iprefix
+
+import (
+	"net/netip"
+	"testing"
+)
+
+func TestNAT64(t *testing.T) {
+	addr, ok := NAT64IPv4(netip.MustParseAddr("64:ff9b::10.1.2.3"), NAT64WellKnown)
+	if !ok || addr.String() != "10.1.2.3" {
+		t.Error(addr, ok)
+	}
+	if addr, ok = NAT64IPv4(netip.MustParseAddr("64:ff9c::10.1.2.3"), NAT64WellKnown); ok {
+		t.Error(addr)
+	}
+
+	custom := netip.MustParsePrefix("2001:db8:64::/96")
+	tests := []struct {
+		s         string
+		prefix    netip.Prefix
+		alongside bool
+		expected  []string
+	}{
+		{"64:ff9b::10.0.0.0/112", NAT64WellKnown, false, []string{"10.0.*"}},
+		{"64:ff9b::10.0.0.0/112", NAT64WellKnown, true, []string{"10.0.*", "64:ff9b::a00:*"}},
+		{"2001:db8:64::a00:0/112", custom, false, []string{"10.0.*"}},
+		{"2001:db8:64::a00:0/112", NAT64WellKnown, false, []string{"2001:db8:64::a00:*"}},
+		{"2001:db8:64::a00:0/112", netip.MustParsePrefix("2001:db8::/32"), false, []string{"2001:db8:64::a00:*"}},
+	}
+	for _, mt := range tests {
+		ps, err := Process(mt.s, NAT64(mt.prefix, mt.alongside))
+		if err != nil || !validate(ps, mt.expected) {
+			t.Error(mt.s, ps, err)
+		}
+	}
+
+	ps, err := ProcessMany([]string{"64:ff9b::10.0.0.0/112", "64:ff9b::12.0.0.0/112"}, NAT64(NAT64WellKnown, false))
+	if err != nil || !validate(ps, []string{"10.0.*", "12.0.*"}) {
+		t.Error(ps, err)
+	}
+}
+
+func TestSixToFour(t *testing.T) {
+	addr, ok := SixToFourIPv4(netip.MustParseAddr("2002:c000:204::1"))
+	if !ok || addr.String() != "192.0.2.4" {
+		t.Error(addr, ok)
+	}
+	if addr, ok = SixToFourIPv4(netip.MustParseAddr("2003::1")); ok {
+		t.Error(addr)
+	}
+
+	tests := []struct {
+		s         string
+		alongside bool
+		expected  []string
+	}{
+		{"2002:c000:200::/40", false, []string{"192.0.2.*"}},
+		{"2002:c000:200::/40", true, []string{"192.0.2.*", "2002:c000:200:*", "2002:c000:201:*", "2002:c000:202:*"}},
+		{"2002:0a00::/24", false, []string{"10.*"}},
+	}
+	for _, mt := range tests {
+		ps, err := Process(mt.s, Decode6to4(mt.alongside), Limit(4))
+		if err != nil || !validate(ps, mt.expected) {
+			t.Error(mt.s, ps, err)
+		}
+	}
+
+	ps, err := ProcessMany([]string{"2002:a00::/24", "2002:c00::/24"}, Decode6to4(false))
+	if err != nil || !validate(ps, []string{"10.*", "12.*"}) {
+		t.Error(ps, err)
+	}
+}
+
+func TestTeredo(t *testing.T) {
+	// the example of RFC 4380 section 4
+	server, client, ok := TeredoIPv4(netip.MustParseAddr("2001:0:4136:e378:8000:63bf:3fff:fdd2"))
+	if !ok || server.String() != "65.54.227.120" || client.String() != "192.0.2.45" {
+		t.Error(server, client, ok)
+	}
+	if _, _, ok = TeredoIPv4(netip.MustParseAddr("2001:1::1")); ok {
+		t.Error("2001:1::1")
+	}
+}
@@ -0,0 +1,87 @@
+// Copyright 2023-now by lifenjoiner. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE file.
+
+package iprefix
+
+import (
+	"bufio"
+	"io"
+)
+
+// Encoder writes patterns line by line to an io.Writer as they are generated,
+// so huge expansions never live fully in memory, except for OrderLexical that
+// has to sort them all.
+type Encoder struct {
+	w *bufio.Writer
+	o *options
+}
+
+// NewEncoder returns an Encoder writing to `w`, tuned by `opts`.
+func NewEncoder(w io.Writer, opts ...Option) *Encoder {
+	return &Encoder{bufio.NewWriter(w), newOptions(opts)}
+}
+
+// Encode writes the patterns of a pattern, a CIDR, an IP range `IP1-IP2` or an
+// IP. With MaxPatterns, the ones before exceeding are already written.
+func (enc *Encoder) Encode(s string) error {
+	gen, err := enc.o.generator(s)
+	if err != nil {
+		return err
+	}
+	return enc.encode(gen)
+}
+
+// EncodeCIDR writes the patterns of CIDR.
+func (enc *Encoder) EncodeCIDR(s string) error {
+	if _, err := parsePrefix(s); err != nil {
+		return err
+	}
+	return enc.Encode(s)
+}
+
+// EncodeRange writes the patterns of IP range.
+// `s` is start IP. `e` is end IP.
+func (enc *Encoder) EncodeRange(s, e string) error {
+	if _, _, err := parseRange(s, e); err != nil {
+		return err
+	}
+	return enc.Encode(s + "-" + e)
+}
+
+func (enc *Encoder) encode(gen func(yield func(string) bool) bool) (err error) {
+	o := enc.o
+	if o.order == OrderLexical {
+		var ps []string
+		if ps, err = o.output(gen); err != nil {
+			return
+		}
+		for _, p := range ps {
+			if err = enc.line(p); err != nil {
+				return
+			}
+		}
+		return enc.w.Flush()
+	}
+	var n int
+	gen(func(p string) bool {
+		n++
+		var more bool
+		if more, err = o.more(n); err != nil {
+			return false
+		}
+		err = enc.line(o.text(p))
+		return err == nil && more
+	})
+	if ferr := enc.w.Flush(); err == nil {
+		err = ferr
+	}
+	return
+}
+
+func (enc *Encoder) line(p string) error {
+	if _, err := enc.w.WriteString(p); err != nil {
+		return err
+	}
+	return enc.w.WriteByte('\n')
+}
@@ -0,0 +1,52 @@
+// Copyright 2023-now by lifenjoiner. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE file.
+
+package iprefix
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestEncoder(t *testing.T) {
+	var b strings.Builder
+	enc := NewEncoder(&b)
+	if err := enc.EncodeCIDR("10.0.0.0/23"); err != nil {
+		t.Error(err)
+	}
+	if err := enc.EncodeRange("192.168.0.1", "192.168.0.2"); err != nil {
+		t.Error(err)
+	}
+	if err := enc.Encode("::1"); err != nil {
+		t.Error(err)
+	}
+	if b.String() != "10.0.0.*\n10.0.1.*\n192.168.0.1\n192.168.0.2\n::1\n" {
+		t.Error(b.String())
+	}
+	if err := enc.EncodeCIDR("10.0.0.1"); err == nil {
+		t.Error("10.0.0.1")
+	}
+	if err := enc.EncodeRange("10.0.0.2", "10.0.0.1"); err == nil {
+		t.Error("10.0.0.2-10.0.0.1")
+	}
+
+	b.Reset()
+	enc = NewEncoder(&b, Wildcard("%"), Limit(2))
+	if err := enc.Encode("10.0.0.0/22"); err != nil || b.String() != "10.0.0.%\n10.0.1.%\n" {
+		t.Error(b.String(), err)
+	}
+
+	b.Reset()
+	enc = NewEncoder(&b, Sort(OrderLexical), Limit(2))
+	if err := enc.Encode("9.255.255.0-10.0.0.255"); err != nil || b.String() != "10.0.0.*\n9.255.255.*\n" {
+		t.Error(b.String(), err)
+	}
+
+	b.Reset()
+	enc = NewEncoder(&b, MaxPatterns(2))
+	if err := enc.Encode("10.0.0.0/22"); !errors.Is(err, ErrTooManyPatterns) || b.String() != "10.0.0.*\n10.0.1.*\n" {
+		t.Error(b.String(), err)
+	}
+}
@@ -0,0 +1,58 @@
+// Copyright 2023-now by lifenjoiner. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE file.
+
+package iprefix
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+)
+
+// The kinds of errors, wrapped by the returned ones with details, so callers
+// can tell them by errors.Is.
+var (
+	ErrInvalidCIDR      = errors.New("invalid CIDR")
+	ErrInvalidIP        = errors.New("invalid IP")
+	ErrInvalidPattern   = errors.New("invalid pattern")
+	ErrAmbiguousPattern = errors.New("ambiguous pattern")
+	ErrMixedFamilies    = errors.New("mixed address families")
+	ErrReversedRange    = errors.New("reversed range")
+	ErrTooManyPatterns  = errors.New("too many patterns")
+	ErrCoverMismatch    = errors.New("coverage mismatch")
+	ErrInvalidCompiled  = errors.New("invalid compiled set")
+)
+
+// EntryError is the error of an entry in a batch, telling which one failed.
+type EntryError struct {
+	Input string
+	// Line is the 1-based position of the entry in the lines or the list.
+	Line int
+	Err  error
+}
+
+func (e *EntryError) Error() string {
+	return fmt.Sprintf("line %d: %q: %v", e.Line, e.Input, e.Err)
+}
+
+func (e *EntryError) Unwrap() error {
+	return e.Err
+}
+
+// parsePrefix is netip.ParsePrefix with the error wrapping ErrInvalidCIDR.
+func parsePrefix(s string) (p netip.Prefix, err error) {
+	if p, err = netip.ParsePrefix(s); err != nil {
+		err = fmt.Errorf("%w: %w", ErrInvalidCIDR, err)
+	}
+	return
+}
+
+// parseAddr is netip.ParseAddr with the error wrapping ErrInvalidIP. The zone
+// of an IPv6 address is dropped, the patterns are of the addresses.
+func parseAddr(s string) (addr netip.Addr, err error) {
+	if addr, err = netip.ParseAddr(s); err != nil {
+		err = fmt.Errorf("%w: %w", ErrInvalidIP, err)
+	}
+	return addr.WithZone(""), err
+}
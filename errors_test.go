@@ -0,0 +1,60 @@
+// Copyright 2023-now by lifenjoiner. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE file.
+
+package iprefix
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestErrors(t *testing.T) {
+	tests := []struct {
+		s        string
+		expected error
+	}{
+		{"10.0.0.0/33", ErrInvalidCIDR},
+		{"10.0.0.0 0.0.1.254", ErrInvalidCIDR},
+		{"10.0.0.x", ErrInvalidIP},
+		{"10.0.0.1-x", ErrInvalidIP},
+		{"10.0.0.1-::1", ErrMixedFamilies},
+		{"10.0.0.2-10.0.0.1", ErrReversedRange},
+	}
+	for _, mt := range tests {
+		if _, err := Process(mt.s); !errors.Is(err, mt.expected) {
+			t.Error(mt.s, err)
+		}
+	}
+
+	if _, err := ProcessCIDR("x"); !errors.Is(err, ErrInvalidCIDR) {
+		t.Error(err)
+	}
+	if _, err := ProcessRange("10.0.0.1", "::1"); !errors.Is(err, ErrMixedFamilies) {
+		t.Error(err)
+	}
+	for _, s := range []string{"10.1.2.3.*", "1:x:*", "::ffff:1:2.*"} {
+		if _, err := ParsePattern(s); !errors.Is(err, ErrInvalidPattern) {
+			t.Error(s, err)
+		}
+	}
+}
+
+func TestEntryError(t *testing.T) {
+	_, err := NewPatternSet([]string{"10.0.0.0/8", "10.0.0.0/33"})
+	var ee *EntryError
+	if !errors.As(err, &ee) || ee.Line != 2 || ee.Input != "10.0.0.0/33" || !errors.Is(err, ErrInvalidCIDR) {
+		t.Error(err)
+	}
+	if err.Error() != `line 2: "10.0.0.0/33": invalid CIDR: netip.ParsePrefix("10.0.0.0/33"): prefix length out of range` {
+		t.Error(err)
+	}
+
+	rs, err := ProcessAll([]string{"# x", "10.0.0.2-10.0.0.1", "", "x"})
+	if !errors.Is(err, ErrReversedRange) || !errors.Is(err, ErrInvalidIP) {
+		t.Error(err)
+	}
+	if len(rs) != 2 || !errors.As(rs[0].Err, &ee) || ee.Line != 2 || !errors.As(rs[1].Err, &ee) || ee.Line != 4 {
+		t.Error(rs)
+	}
+}
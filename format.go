@@ -0,0 +1,326 @@
+// Copyright 2023-now by lifenjoiner. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE file.
+
+package iprefix
+
+import (
+	"fmt"
+	"net/netip"
+	"strconv"
+	"strings"
+)
+
+// blockBits returns the prefix length of the blocks that patterns stand for:
+// octets for IPv4 and 4in6, hextets or nibbles for IPv6.
+func blockBits(p netip.Prefix, nibble bool) int {
+	m := p.Bits()
+	addr := p.Addr()
+	switch {
+	case addr.Is4():
+		return (m + 7) / 8 * 8
+	case addr.Is4In6() && m >= 96:
+		return 96 + (m-96+7)/8*8
+	case nibble:
+		return (m + 3) / 4 * 4
+	}
+	return (m + 15) / 16 * 16
+}
+
+// addBit adds 1 at the bit position `i` of the big-endian IP.
+func addBit(ip []byte, i int) {
+	j := i / 8
+	v := uint(ip[j]) + 0x80>>(i%8)
+	ip[j] = byte(v)
+	for v > 0xff && j > 0 {
+		j--
+		v = uint(ip[j]) + 1
+		ip[j] = byte(v)
+	}
+}
+
+// blocks splits the prefix into the blocks that patterns stand for.
+func blocks(p netip.Prefix, nibble bool, yield func(netip.Prefix) bool) bool {
+	p = p.Masked()
+	b := blockBits(p, nibble)
+	if b == 0 {
+		// must have a prefix
+		switch {
+		case p.Addr().Is4():
+			b = 8
+		case nibble:
+			b = 4
+		default:
+			b = 16
+		}
+	}
+	n := 1 << (b - p.Bits())
+	ip := p.Addr().AsSlice()
+	for i := 0; i < n; i++ {
+		addr, _ := netip.AddrFromSlice(ip)
+		if !yield(netip.PrefixFrom(addr, b)) {
+			return false
+		}
+		if i < n-1 {
+			addBit(ip, b-1)
+		}
+	}
+	return true
+}
+
+func formatV4(ip []byte, m int) string {
+	var b strings.Builder
+	for i := 0; i < m/8; i++ {
+		if i > 0 {
+			b.WriteByte('.')
+		}
+		b.WriteString(strconv.Itoa(int(ip[i])))
+	}
+	if m < 32 {
+		if m > 0 {
+			b.WriteByte('.')
+		}
+		b.WriteByte('*')
+	}
+	return b.String()
+}
+
+// formatExpanded formats a block without zero compression. The leading
+// nibbles of a partial hextet are written zero-padded.
+func formatExpanded(p netip.Prefix, pad bool) string {
+	addr := p.Addr()
+	m := p.Bits()
+	ip := addr.AsSlice()
+	if addr.Is4() {
+		return formatV4(ip, m)
+	}
+	n := m / 16
+	is4In6 := addr.Is4In6() && m >= 96
+	if is4In6 {
+		n = 6
+	}
+	var b strings.Builder
+	for i := 0; i < n; i++ {
+		if i > 0 {
+			b.WriteByte(':')
+		}
+		if pad {
+			fmt.Fprintf(&b, "%04x", beUint16(ip, i))
+		} else {
+			b.WriteString(strconv.FormatUint(uint64(beUint16(ip, i)), 16))
+		}
+	}
+	if is4In6 {
+		return b.String() + ":" + formatV4(ip[12:], m-96)
+	}
+	if n < 8 {
+		if n > 0 {
+			b.WriteByte(':')
+		}
+		if k := m % 16 / 4; k > 0 {
+			b.WriteString(fmt.Sprintf("%04x", beUint16(ip, n))[:k])
+		}
+		b.WriteByte('*')
+	}
+	return b.String()
+}
+
+func digitClass(x, y byte) string {
+	if x == y {
+		return string(x)
+	}
+	if x == '0' && y == '9' {
+		return "[0-9]"
+	}
+	return "[" + string(x) + "-" + string(y) + "]"
+}
+
+// digitGlobs matches the decimals from `a` to `b` of the same length.
+func digitGlobs(a, b string) []string {
+	if len(a) == 1 {
+		return []string{digitClass(a[0], b[0])}
+	}
+	var gs []string
+	if a[0] == b[0] {
+		for _, g := range digitGlobs(a[1:], b[1:]) {
+			gs = append(gs, a[0:1]+g)
+		}
+		return gs
+	}
+	n := len(a) - 1
+	lo, hi := a[0], b[0]
+	if a[1:] != strings.Repeat("0", n) {
+		for _, g := range digitGlobs(a[1:], strings.Repeat("9", n)) {
+			gs = append(gs, a[0:1]+g)
+		}
+		lo++
+	}
+	if b[1:] != strings.Repeat("9", n) {
+		hi--
+	}
+	if lo <= hi {
+		gs = append(gs, digitClass(lo, hi)+strings.Repeat("[0-9]", n))
+	}
+	if hi < b[0] {
+		for _, g := range digitGlobs(strings.Repeat("0", n), b[1:]) {
+			gs = append(gs, b[0:1]+g)
+		}
+	}
+	return gs
+}
+
+// decimalGlobs matches the decimals from `lo` to `hi` without leading zeros.
+func decimalGlobs(lo, hi int) (gs []string) {
+	for min := 0; min <= hi; {
+		max := min*10 - 1
+		if min == 0 {
+			max = 9
+		}
+		if lo <= max {
+			a := lo
+			if a < min {
+				a = min
+			}
+			b := hi
+			if b > max {
+				b = max
+			}
+			gs = append(gs, digitGlobs(strconv.Itoa(a), strconv.Itoa(b))...)
+		}
+		if min == 0 {
+			min = 10
+		} else {
+			min *= 10
+		}
+	}
+	return
+}
+
+// globPrefix generates path.Match patterns of an IPv4 or 4in6 prefix, using
+// digit classes for the partial octet.
+func globPrefix(p netip.Prefix, yield func(string) bool) bool {
+	p = p.Masked()
+	addr := p.Addr()
+	ip := addr.AsSlice()
+	m := p.Bits()
+	head := ""
+	if addr.Is6() {
+		head = "::ffff:"
+		ip = ip[12:]
+		m -= 96
+	}
+	k := m / 8
+	if m%8 == 0 {
+		if m == 0 {
+			k = 0
+		} else {
+			return yield(head + formatV4(ip, m))
+		}
+	}
+	fixed := formatV4(ip, 8*k)
+	fixed = strings.TrimSuffix(fixed, "*")
+	tail := ""
+	if k < 3 {
+		tail = ".*"
+	}
+	lo := int(ip[k])
+	hi := lo + 1<<(8-m%8) - 1
+	if m%8 == 0 {
+		hi = 0xff
+	}
+	for _, g := range decimalGlobs(lo, hi) {
+		if !yield(head + fixed + g + tail) {
+			return false
+		}
+	}
+	return true
+}
+
+// RPZTrigger returns the owner name of the DNS RPZ IP trigger of the prefix,
+// like `24.0.2.0.192.rpz-ip` and `48.zz.2.2001.rpz-ip`, or "" for an invalid
+// prefix, such as the zero value.
+func RPZTrigger(p netip.Prefix) string {
+	if !p.IsValid() {
+		return ""
+	}
+	p = p.Masked()
+	addr := p.Addr()
+	ip := addr.AsSlice()
+	var labels []string
+	if addr.Is4() {
+		for _, b := range ip {
+			labels = append(labels, strconv.Itoa(int(b)))
+		}
+	} else {
+		// the longest zero run, the first one if tied
+		zs, zn := -1, 1
+		for i := 0; i < 8; {
+			j := i
+			for j < 8 && beUint16(ip, j) == 0 {
+				j++
+			}
+			if j-i > zn {
+				zs, zn = i, j-i
+			}
+			i = j + 1
+		}
+		for i := 0; i < 8; i++ {
+			if i == zs {
+				labels = append(labels, "zz")
+				i += zn - 1
+				continue
+			}
+			labels = append(labels, strconv.FormatUint(uint64(beUint16(ip, i)), 16))
+		}
+	}
+	var b strings.Builder
+	b.WriteString(strconv.Itoa(p.Bits()))
+	for i := len(labels) - 1; i >= 0; i-- {
+		b.WriteByte('.')
+		b.WriteString(labels[i])
+	}
+	b.WriteString(".rpz-ip")
+	return b.String()
+}
+
+// ProcessRPZ generates DNS RPZ IP trigger records from a pattern, a CIDR,
+// an IP range `IP1-IP2` or an IP, with the NXDOMAIN action `CNAME .`.
+func ProcessRPZ(s string) (rs []string, err error) {
+	r, err := parseEntry(s)
+	if err != nil {
+		return
+	}
+	for _, p := range r.prefixes() {
+		rs = append(rs, RPZTrigger(p)+" CNAME .")
+	}
+	return
+}
+
+// WildcardMask returns the Cisco ACL address and inverse mask pair of an IPv4
+// prefix, like `10.0.0.0 0.0.1.255`, or "" for the others.
+func WildcardMask(p netip.Prefix) string {
+	if !p.IsValid() || !p.Addr().Is4() {
+		return ""
+	}
+	p = p.Masked()
+	w := ^uint32(0) >> p.Bits()
+	mask := netip.AddrFrom4([4]byte{byte(w >> 24), byte(w >> 16), byte(w >> 8), byte(w)})
+	return p.Addr().String() + " " + mask.String()
+}
+
+// ProcessWildcardMask generates Cisco ACL address and inverse mask pairs from
+// an IPv4 pattern, CIDR, IP range `IP1-IP2` or IP.
+func ProcessWildcardMask(s string) (ws []string, err error) {
+	r, err := parseEntry(s)
+	if err != nil {
+		return
+	}
+	if !r.from.Is4() {
+		err = fmt.Errorf("%w: not IPv4: %v", ErrMixedFamilies, s)
+		return
+	}
+	for _, p := range r.prefixes() {
+		ws = append(ws, WildcardMask(p))
+	}
+	return
+}
@@ -0,0 +1,221 @@
+// Copyright 2023-now by lifenjoiner. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE file.
+
+package iprefix
+
+import (
+	"net/netip"
+	"path"
+	"strings"
+	"testing"
+)
+
+func TestBlocks(t *testing.T) {
+	tests := []struct {
+		p        string
+		expected string
+	}{
+		{"10.0.0.0/15", "10.0.0.0/16 10.1.0.0/16"},
+		{"10.0.0.1/32", "10.0.0.1/32"},
+		{"0.0.0.0/0", ""},
+		{"2001:db8::/31", "2001:db8::/32 2001:db9::/32"},
+		{"::ffff:10.0.0.0/103", "::ffff:10.0.0.0/104 ::ffff:11.0.0.0/104"},
+		{"::ffff:0:0/96", "::ffff:0.0.0.0/96"},
+		{"::ffff:0:0/95", "::fffe:0:0/96 ::ffff:0.0.0.0/96"},
+		{"::/127", "::/128 ::1/128"},
+		{"255.255.255.254/31", "255.255.255.254/32 255.255.255.255/32"},
+	}
+	for _, mt := range tests {
+		var r []string
+		blocks(netip.MustParsePrefix(mt.p), false, func(b netip.Prefix) bool {
+			r = append(r, b.String())
+			return true
+		})
+		if len(mt.expected) > 0 && strings.Join(r, " ") != mt.expected {
+			t.Error(mt.p, r)
+		}
+		if len(mt.expected) == 0 && len(r) != 256 {
+			t.Error(mt.p, len(r))
+		}
+	}
+}
+
+func TestFormExpanded(t *testing.T) {
+	tests := []struct {
+		s        string
+		form     IPv6Form
+		expected []string
+	}{
+		{"2001:20::/31", IPv6Expanded, []string{"2001:20:*", "2001:21:*"}},
+		{"1111::/48", IPv6Expanded, []string{"1111:0:0:*"}},
+		{"1111::/48", IPv6Padded, []string{"1111:0000:0000:*"}},
+		{"::1/127", IPv6Padded, []string{"0000:0000:0000:0000:0000:0000:0000:0000", "0000:0000:0000:0000:0000:0000:0000:0001"}},
+		{"::ffff:10.0.0.0/111", IPv6Expanded, []string{"0:0:0:0:0:ffff:10.0.*", "0:0:0:0:0:ffff:10.1.*"}},
+		{"::ffff:10.0.0.1", IPv6Padded, []string{"0000:0000:0000:0000:0000:ffff:10.0.0.1"}},
+		{"10.0.0.254-10.0.1.255", IPv6Expanded, []string{"10.0.0.254", "10.0.0.255", "10.0.1.*"}},
+	}
+	for _, mt := range tests {
+		r, err := Process(mt.s, Form(mt.form))
+		if err != nil || strings.Join(r, " ") != strings.Join(mt.expected, " ") {
+			t.Error(mt.s, r, err)
+		}
+	}
+}
+
+func TestNibble(t *testing.T) {
+	tests := []struct {
+		s        string
+		expected []string
+	}{
+		{"2001:db8:0:1000::/52", []string{"2001:0db8:0000:1*"}},
+		{"2001:db8::/47", []string{"2001:0db8:0000:*", "2001:0db8:0001:*"}},
+		{"2001:db8::/28", []string{"2001:0db*"}},
+		{"2001:db8:0:1000::/51", []string{"2001:0db8:0000:0*", "2001:0db8:0000:1*"}},
+		{"::1/127", []string{"0000:0000:0000:0000:0000:0000:0000:0000", "0000:0000:0000:0000:0000:0000:0000:0001"}},
+		{"::ffff:10.0.0.0/111", []string{"0000:0000:0000:0000:0000:ffff:10.0.*", "0000:0000:0000:0000:0000:ffff:10.1.*"}},
+		{"10.0.0.0/15", []string{"10.0.*", "10.1.*"}},
+	}
+	for _, mt := range tests {
+		r, err := Process(mt.s, Nibble())
+		if err != nil || strings.Join(r, " ") != strings.Join(mt.expected, " ") {
+			t.Error(mt.s, r, err)
+		}
+	}
+	r, _ := Process("::/0", Nibble())
+	if len(r) != 16 || r[15] != "f*" {
+		t.Error(r)
+	}
+
+	// whatever the order of Form
+	for _, form := range []IPv6Form{IPv6All, IPv6Canonical, IPv6Expanded, IPv6Padded} {
+		for _, opts := range [][]Option{{Nibble(), Form(form)}, {Form(form), Nibble()}} {
+			r, err := Process("2001:db8::/47", opts...)
+			if err != nil || strings.Join(r, " ") != "2001:0db8:0000:* 2001:0db8:0001:*" {
+				t.Error(form, r, err)
+			}
+		}
+	}
+}
+
+func TestDecimalGlobs(t *testing.T) {
+	tests := []struct {
+		lo, hi   int
+		expected string
+	}{
+		{0, 255, "[0-9] [1-9][0-9] 1[0-9][0-9] 2[0-4][0-9] 25[0-5]"},
+		{16, 31, "1[6-9] 2[0-9] 3[0-1]"},
+		{0, 7, "[0-7]"},
+		{96, 127, "9[6-9] 1[0-1][0-9] 12[0-7]"},
+		{128, 255, "12[8-9] 1[3-9][0-9] 2[0-4][0-9] 25[0-5]"},
+		{200, 200, "200"},
+	}
+	for _, mt := range tests {
+		gs := decimalGlobs(mt.lo, mt.hi)
+		if strings.Join(gs, " ") != mt.expected {
+			t.Error(mt.lo, mt.hi, gs)
+		}
+	}
+}
+
+func TestSyntaxGlob(t *testing.T) {
+	tests := []struct {
+		s        string
+		expected []string
+	}{
+		{"10.0.0.16/28", []string{"10.0.0.1[6-9]", "10.0.0.2[0-9]", "10.0.0.3[0-1]"}},
+		{"10.0.0.0/20", []string{"10.0.[0-9].*", "10.0.1[0-5].*"}},
+		{"10.0.0.0/15", []string{"10.[0-1].*"}},
+		{"10.0.0.0/16", []string{"10.0.*"}},
+		{"::ffff:10.0.0.0/125", []string{"::ffff:10.0.0.[0-7]"}},
+		{"2001:20::/111", []string{"2001:20::*", "2001:20::1:*"}},
+	}
+	for _, mt := range tests {
+		r, err := Process(mt.s, Output(SyntaxGlob))
+		if err != nil || strings.Join(r, " ") != strings.Join(mt.expected, " ") {
+			t.Error(mt.s, r, err)
+		}
+	}
+
+	// exact for path.Match
+	r, _ := Process("10.0.0.100-10.0.1.200", Output(SyntaxGlob))
+	for i := 0; i < 0x200; i++ {
+		addr := netip.AddrFrom4([4]byte{10, 0, byte(i >> 8), byte(i)})
+		in := i >= 100 && i <= 0x100+200
+		matched := false
+		for _, g := range r {
+			if ok, _ := path.Match(g, addr.String()); ok {
+				matched = true
+				break
+			}
+		}
+		if matched != in {
+			t.Error(addr, r)
+		}
+	}
+}
+
+func TestRPZ(t *testing.T) {
+	tests := []struct {
+		p        string
+		expected string
+	}{
+		{"192.0.2.0/24", "24.0.2.0.192.rpz-ip"},
+		{"10.0.0.1/32", "32.1.0.0.10.rpz-ip"},
+		{"2001:2::/48", "48.zz.2.2001.rpz-ip"},
+		{"2001:db8:0:0:1::/80", "80.zz.1.0.0.db8.2001.rpz-ip"},
+		{"::1/128", "128.1.zz.rpz-ip"},
+		{"::/0", "0.zz.rpz-ip"},
+		{"1:0:2:0:3:0:4:0/128", "128.0.4.0.3.0.2.0.1.rpz-ip"},
+	}
+	for _, mt := range tests {
+		if r := RPZTrigger(netip.MustParsePrefix(mt.p)); r != mt.expected {
+			t.Error(mt.p, r)
+		}
+	}
+	if r := RPZTrigger(netip.Prefix{}); r != "" {
+		t.Error(r)
+	}
+
+	r, err := ProcessRPZ("10.0.0.254-10.0.1.255")
+	if err != nil || strings.Join(r, "\n") != "31.254.0.0.10.rpz-ip CNAME .\n24.0.1.0.10.rpz-ip CNAME ." {
+		t.Error(r, err)
+	}
+	if _, err = ProcessRPZ("x"); err == nil {
+		t.Error("x")
+	}
+}
+
+func TestWildcardMask(t *testing.T) {
+	tests := []struct {
+		p        string
+		expected string
+	}{
+		{"10.0.0.0/23", "10.0.0.0 0.0.1.255"},
+		{"10.0.1.7/32", "10.0.1.7 0.0.0.0"},
+		{"10.0.1.7/24", "10.0.1.0 0.0.0.255"},
+		{"0.0.0.0/0", "0.0.0.0 255.255.255.255"},
+		{"2001:db8::/32", ""},
+		{"::ffff:10.0.0.0/104", ""},
+	}
+	for _, mt := range tests {
+		if w := WildcardMask(netip.MustParsePrefix(mt.p)); w != mt.expected {
+			t.Error(mt.p, w)
+		}
+	}
+	if w := WildcardMask(netip.Prefix{}); w != "" {
+		t.Error(w)
+	}
+
+	ws, err := ProcessWildcardMask("10.0.0.254-10.0.1.255")
+	if err != nil || strings.Join(ws, ",") != "10.0.0.254 0.0.0.1,10.0.1.0 0.0.0.255" {
+		t.Error(ws, err)
+	}
+	if _, err = ProcessWildcardMask("::/64"); err == nil {
+		t.Error("::/64")
+	}
+	ps, err := Process("10.0.0.0 0.0.1.255")
+	if err != nil || !validate(ps, []string{"10.0.0.*", "10.0.1.*"}) {
+		t.Error(ps, err)
+	}
+}
@@ -8,7 +8,7 @@ package iprefix
 import (
 	"fmt"
 	"net/netip"
-	"strings"
+	"strconv"
 )
 
 func beUint16(ip []byte, i int) uint16 {
@@ -20,366 +20,463 @@ func setbeUint16(ip []byte, i int, v uint16) {
 	ip[2*i+1] = byte(v)
 }
 
-func genV4(ip []byte, block int, sv, ev uint8) (ps []string) {
+// genV4 generates the patterns of the values `sv` to `ev` of the octet `block`,
+// the text led by `head`.
+func genV4(head string, ip []byte, block int, sv, ev uint8, yield func([]byte) bool) bool {
+	// the leading octets are the same, only the block one varies
+	b := getBuf()
+	buf := append(*b, head...)
+	defer func() { putBuf(b, buf) }()
+	for j := 0; j < block; j++ {
+		buf = strconv.AppendUint(buf, uint64(ip[j]), 10)
+		buf = append(buf, '.')
+	}
+	n := len(buf)
 	for i := sv; i <= ev; i++ {
 		ip[block] = i
-		ipr := ""
-		for j := 0; j <= block; j++ {
-			ipr += fmt.Sprintf("%d", ip[j])
-			if j < 3 {
-				ipr += "."
-			}
-		}
+		buf = strconv.AppendUint(buf[:n], uint64(i), 10)
 		if block < 3 {
-			ipr += "*"
+			buf = append(buf, '.', '*')
+		}
+		if !yield(buf) {
+			return false
 		}
-		ps = append(ps, ipr)
 		if i == ev {
 			break
 		}
 	}
+	return true
+}
+
+// zeroRun returns the zero hextets [start, end) that the canonical text
+// compresses, the longest run of at least 2, the first if tied, or -1.
+func zeroRun(h *[8]uint16) (start, end int) {
+	start, end = -1, -1
+	for i := 0; i < 8; i++ {
+		if h[i] != 0 {
+			continue
+		}
+		j := i + 1
+		for j < 8 && h[j] == 0 {
+			j++
+		}
+		if j-i >= 2 && j-i > end-start {
+			start, end = i, j
+		}
+		i = j
+	}
 	return
 }
 
-func genV6(ip []byte, block int, sv, ev uint16, is4In6 bool) (ps []string) {
-	tail := ""
-	for i := block + 1; i < 8; i++ {
-		setbeUint16(ip, i, 0xffff)
-		if is4In6 {
-			switch i {
-			case 6:
-				tail += ":255.255"
-			case 7:
-				tail += ".255.255"
-			}
-		} else {
-			tail += ":ffff"
+func appendHextets(b []byte, hs []uint16) []byte {
+	for i, x := range hs {
+		if i > 0 {
+			b = append(b, ':')
 		}
+		b = strconv.AppendUint(b, uint64(x), 16)
 	}
-	ext := "0"
-	eb4In6 := ev
-	el8 := ev & 0xff
-	if el8 != 0xff && el8 != 0 {
-		eb4In6 &= 0xff00
+	return b
+}
+
+// appendCanonical appends the canonical text of an IPv6 address, not 4in6.
+func appendCanonical(b []byte, h *[8]uint16) []byte {
+	start, end := zeroRun(h)
+	if start < 0 {
+		return appendHextets(b, h[:])
 	}
-	step := uint16(1)
-	for i := sv; i <= ev; i += step {
-		setbeUint16(ip, block, i)
-		addr := netip.AddrFrom16([16]byte(ip))
-		ipr := strings.TrimSuffix(addr.String(), tail)
-		if is4In6 {
-			if step == 1 && i&0xff == 0 && i < eb4In6 {
-				step = 0x100
-			} else if i >= eb4In6 {
-				step = 1
-			}
-			if step == 0x100 {
-				ipr = ipr[:len(ipr)-2]
-			}
+	b = appendHextets(b, h[:start])
+	b = append(b, ':', ':')
+	return appendHextets(b, h[end:])
+}
+
+// cut is where the canonical texts of a block split between the `k` leading
+// hextets and the others: the compressed zero run [start, end) starting in the
+// leading ones, with `end` capped at `k`, or -1 if none.
+type cut struct {
+	start, end int
+	// literal means only the address of zero trailing hextets is spelled so.
+	literal bool
+}
+
+// cuts returns the cuts of the canonical texts of the block with the `k`
+// leading hextets of `h`, by every shape of the zero trailing hextets. The
+// first one is of the all nonzero shape, as canonical as the block is.
+func cuts(h *[8]uint16, k int, form IPv6Form) (cs []cut) {
+	free := 8 - k
+	all := 1<<free - 1
+	for s := 0; s <= all; s++ {
+		x := *h
+		for j := 0; j < free; j++ {
+			x[k+j] = uint16(s>>j&1 ^ 1)
+		}
+		start, end := zeroRun(&x)
+		if start >= k {
+			start, end = -1, -1
+		} else if end > k {
+			end = k
+		}
+		i := 0
+		for i < len(cs) && (cs[i].start != start || cs[i].end != end) {
+			i++
+		}
+		if i == len(cs) {
+			// the all zero shape is the last one
+			cs = append(cs, cut{start, end, s == all})
 		}
-		dCount := block + 1
-		if block < 7 || step == 0x100 {
-			if is4In6 {
-				ipr += ".*"
+		if form != IPv6All {
+			break
+		}
+	}
+	return
+}
+
+// spelling is a spelling of the patterns of a block, the invariant text
+// before and after the written value, or the whole text if the value is in
+// the compressed zero run.
+type spelling struct {
+	head, tail string
+	text       string
+	vary       bool
+}
+
+// spellings returns the spellings of the block `k-1` for the cuts of `h`, the
+// same for all the nonzero values.
+func spellings(h *[8]uint16, k int, form IPv6Form) (ss []spelling) {
+	block := k - 1
+	var b []byte
+	for _, c := range cuts(h, k, form) {
+		var sp spelling
+		switch {
+		case c.start >= 0 && c.start <= block && block < c.end:
+			if c.literal {
+				// the trailing hextets of `h` are zero
+				b = appendCanonical(b[:0], h)
 			} else {
-				ipr += ":*"
+				b = appendHextets(b[:0], h[:c.start])
+				b = append(b, ':', ':', '*')
 			}
-			dCount++
-		}
-		ps = append(ps, ipr)
-		prs := strings.Split(ipr, ":")
-		pCount := len(prs)
-		sCount := dCount - pCount
-		zCount := sCount + 1
-		mod := false
-		if prs[0] == "" {
-			prs[0] = "0"
-			mod = true
-			zCount++
+			sp.text = string(b)
+		case c.start < 0:
+			b = appendHextets(b[:0], h[:block])
+			if block > 0 {
+				b = append(b, ':')
+			}
+			sp.head, sp.tail, sp.vary = string(b), ":*", true
+			if c.literal {
+				sp.tail = "::"
+			}
+		default:
+			b = appendHextets(b[:0], h[:c.start])
+			b = append(b, ':', ':')
+			b = appendHextets(b, h[c.end:block])
+			if c.end < block {
+				b = append(b, ':')
+			}
+			sp.head, sp.tail, sp.vary = string(b), ":*", true
 		}
-		if zCount < 3 && block < 5 {
-			if i == sv {
-				for j := 0; j < sCount; j++ {
-					ext += ":0"
-				}
+		ss = append(ss, sp)
+	}
+	return
+}
+
+// genV6 generates the patterns of the values `sv` to `ev` of the hextet
+// `block`, spelled from the hextets as the canonical texts start.
+func genV6(ip []byte, block int, sv, ev uint16, form IPv6Form, yield func([]byte) bool) bool {
+	k := block + 1
+	var h [8]uint16
+	for j := 0; j < k; j++ {
+		h[j] = beUint16(ip, j)
+	}
+	// only zero or not of the value matters to the spellings
+	var zero, nonzero []spelling
+	b := getBuf()
+	buf := *b
+	defer func() { putBuf(b, buf) }()
+	for i := sv; i <= ev; i++ {
+		setbeUint16(ip, block, i)
+		h[block] = i
+		if k == 8 {
+			buf = appendCanonical(buf[:0], &h)
+			if !yield(buf) {
+				return false
 			}
-			for j := 1; j < pCount; j++ {
-				if prs[j] == "" {
-					prs[j] = ext
-					mod = true
-					break
-				}
+		} else {
+			ss := &nonzero
+			if i == 0 {
+				ss = &zero
 			}
-			iBlock := block
-			// refer test: IPv6z1, IPv6z2
-			preZero := 0
-			for j := pCount - 2; j >= 0; j-- {
-				if prs[j] == "0" {
-					preZero++
-				} else {
-					break
-				}
+			if *ss == nil {
+				*ss = spellings(&h, k, form)
 			}
-			if preZero > 0 {
-				switch preZero {
-				case 2:
-					// IPv6z2
-					if pCount > 3 {
-						prs[pCount-3] = ""
-						prs[pCount-2] = "*"
-						prs = prs[:pCount-1]
-						mod = true
-						iBlock -= 2
-					}
-				case 1:
-					// IPv6z1
-					if pCount == 2 {
-						// ::/16
-						prs[pCount-1] = ":*"
-					} else if mod && pCount > 2 {
-						ps = append(ps, strings.Join(prs, ":"))
-					}
-					prs[pCount-2] = ""
-					mod = true
-					iBlock--
+			for _, sp := range *ss {
+				if sp.vary {
+					buf = append(buf[:0], sp.head...)
+					buf = strconv.AppendUint(buf, uint64(i), 16)
+					buf = append(buf, sp.tail...)
+				} else {
+					buf = append(buf[:0], sp.text...)
 				}
-			}
-			if mod {
-				if iBlock == 4 {
-					// IPv6s3z
-					prs[pCount-1] = ":"
+				if !yield(buf) {
+					return false
 				}
-				ps = append(ps, strings.Join(prs, ":"))
 			}
 		}
 		if i == ev {
 			break
 		}
 	}
+	return true
+}
+
+// varying returns the block varying in the prefix of `m` bits of `ip`, in
+// blocks of `bs` bits, and its first and last values.
+func varying(ip []byte, m, bs int) (block int, sv, ev uint16) {
+	block = (m - 1) / bs
+	variableBits := m % bs
+	if variableBits > 0 || m == 0 {
+		// must have a prefix
+		variableBits = bs - variableBits
+	}
+	if bs == 8 {
+		sv = uint16(ip[block])
+	} else {
+		sv = beUint16(ip, block)
+	}
+	sv &= 0xffff << variableBits
+	ev = sv + 1<<variableBits - 1
 	return
 }
 
-func processPrefix(p netip.Prefix) (ps []string) {
+func processPrefix(p netip.Prefix, form IPv6Form, yield func(string) bool) bool {
+	return genPrefix(p, form, func(b []byte) bool {
+		return yield(string(b))
+	})
+}
+
+// genPrefix generates the patterns of a prefix as bytes, valid until `yield`
+// returns.
+func genPrefix(p netip.Prefix, form IPv6Form, yield func([]byte) bool) bool {
+	if !p.IsValid() {
+		// nothing for the zero value
+		return true
+	}
 	addr := p.Addr()
 	if p.IsSingleIP() {
-		ps = append(ps, addr.String())
-		return
+		b := getBuf()
+		buf := addr.AppendTo(*b)
+		defer func() { putBuf(b, buf) }()
+		return yield(buf)
+	}
+	m := p.Bits()
+	a16 := addr.As16()
+	ip := a16[:]
+	if addr.Is4() || addr.Is4In6() && m > 96 {
+		head := ""
+		if addr.Is6() {
+			head = "::ffff:"
+			m -= 96
+		}
+		ip = ip[12:]
+		block, sv, ev := varying(ip, m, 8)
+		return genV4(head, ip, block, uint8(sv), uint8(ev), yield)
 	}
+	block, sv, ev := varying(ip, m, 16)
+	return genV6(ip, block, sv, ev, form, yield)
+}
+
+// span is the block varying in a prefix, not of a single IP.
+type span struct {
+	// offset and width of the block in bits
+	offset, width int
+	sv, ev        uint16
+	// the counts of the patterns of the value 0 and of the others
+	zero, nonzero int
+}
+
+func spanOf(p netip.Prefix) (sp span) {
+	addr := p.Addr()
 	m := p.Bits()
 	ip := addr.AsSlice()
-	var ipr []string
-	if addr.Is4() {
-		prefixBlock := int((m - 1) / 8)
-		variableBits := m % 8
-		if variableBits > 0 || m == 0 {
-			// must have a prefix
-			variableBits = 8 - variableBits
+	sp.zero, sp.nonzero = 1, 1
+	if addr.Is4() || addr.Is4In6() && m > 96 {
+		if addr.Is6() {
+			ip = ip[12:]
+			m -= 96
+			sp.offset = 96
 		}
-		sv := ip[prefixBlock] & (0xff << variableBits)
-		ev := sv + 1<<variableBits - 1
-		ipr = genV4(ip, prefixBlock, sv, ev)
-	} else if addr.Is6() {
-		prefixBlock := int((m - 1) / 16)
-		variableBits := m % 16
-		if variableBits > 0 || m == 0 {
-			// must have a prefix
-			variableBits = 16 - variableBits
+		block, sv, ev := varying(ip, m, 8)
+		sp.offset += block * 8
+		sp.width, sp.sv, sp.ev = 8, sv, ev
+		return
+	}
+	block, sv, ev := varying(ip, m, 16)
+	sp.offset, sp.width, sp.sv, sp.ev = block*16, 16, sv, ev
+	if block < 7 {
+		// the count of the spellings only depends on whether the value is zero
+		var h [8]uint16
+		for j := 0; j < block; j++ {
+			h[j] = beUint16(ip, j)
 		}
-		sv := beUint16(ip, prefixBlock) & (0xffff << variableBits)
-		ev := sv + 1<<variableBits - 1
-		ipr = genV6(ip, prefixBlock, sv, ev, addr.Is4In6())
+		sp.zero = len(cuts(&h, block+1, IPv6All))
+		h[block] = 1
+		sp.nonzero = len(cuts(&h, block+1, IPv6All))
+	}
+	return
+}
+
+// count returns the count of the patterns of the values from `v`.
+func (sp span) count(v uint16) int {
+	n := int(sp.ev-v) + 1
+	if v == 0 {
+		return sp.zero + (n-1)*sp.nonzero
+	}
+	return n * sp.nonzero
+}
+
+// PatternCount returns the count of the patterns generated from a prefix,
+// without generating them, or 0 for an invalid prefix.
+func PatternCount(p netip.Prefix) int {
+	if !p.IsValid() {
+		return 0
+	}
+	if p.IsSingleIP() {
+		return 1
 	}
-	ps = append(ps, ipr...)
+	sp := spanOf(p)
+	return sp.count(sp.sv)
+}
+
+// collect gathers all the patterns of a generator into a slice, of capacity
+// `n` at first.
+func collect(n int, gen func(yield func(string) bool) bool) (ps []string) {
+	ps = make([]string, 0, n)
+	gen(func(s string) bool {
+		ps = append(ps, s)
+		return true
+	})
 	return
 }
 
+// prefixesPatterns collects the patterns of the prefixes, allocated once.
+func prefixesPatterns(ps []netip.Prefix) []string {
+	n := 0
+	for _, p := range ps {
+		n += PatternCount(p)
+	}
+	return collect(n, func(yield func(string) bool) bool {
+		for _, p := range ps {
+			if !processPrefix(p, IPv6All, yield) {
+				return false
+			}
+		}
+		return true
+	})
+}
+
+// ProcessPrefix generates string IP prefix pattern from a parsed prefix, or
+// nil for an invalid prefix, such as the zero value.
+func ProcessPrefix(p netip.Prefix) []string {
+	if !p.IsValid() {
+		return nil
+	}
+	return collect(PatternCount(p), func(yield func(string) bool) bool {
+		return processPrefix(p, IPv6All, yield)
+	})
+}
+
 // ProcessCIDR generates string IP prefix pattern from CIDR.
 func ProcessCIDR(s string) (ps []string, err error) {
-	p, err := netip.ParsePrefix(s)
+	p, err := parsePrefix(s)
 	if err != nil {
 		return
 	}
-	return processPrefix(p), nil
+	return ProcessPrefix(p), nil
+}
+
+// ProcessCIDRFunc calls `fn` for each string IP prefix pattern from CIDR.
+// Generation stops when `fn` returns false.
+func ProcessCIDRFunc(s string, fn func(pattern string) bool) error {
+	p, err := parsePrefix(s)
+	if err != nil {
+		return err
+	}
+	processPrefix(p, IPv6All, fn)
+	return nil
 }
 
 // ProcessRange generates string IP prefix pattern from IP range.
 // `s` is start IP. `e` is end IP.
 func ProcessRange(s, e string) (ps []string, err error) {
-	addr1, err := netip.ParseAddr(s)
+	addr1, err := parseAddr(s)
 	if err != nil {
 		return
 	}
-	addr2, err := netip.ParseAddr(e)
+	addr2, err := parseAddr(e)
 	if err != nil {
 		return
 	}
-	if addr1.BitLen() != addr2.BitLen() {
-		err = fmt.Errorf("not the same type: %v Vs %v", addr1, addr2)
+	return ProcessAddrRange(addr1, addr2)
+}
+
+// ProcessRangeFunc calls `fn` for each string IP prefix pattern from IP range.
+// `s` is start IP. `e` is end IP.
+// Generation stops when `fn` returns false.
+func ProcessRangeFunc(s, e string, fn func(pattern string) bool) error {
+	addr1, addr2, err := parseRange(s, e)
+	if err != nil {
+		return err
+	}
+	processRange(addr1, addr2, IPv6All, fn)
+	return nil
+}
+
+// parseRange parses and checks IP range.
+func parseRange(s, e string) (addr1, addr2 netip.Addr, err error) {
+	if addr1, err = parseAddr(s); err != nil {
 		return
 	}
-	switch addr1.Compare(addr2) {
-	case 1:
-		err = fmt.Errorf("%v > %v", addr1, addr2)
+	if addr2, err = parseAddr(e); err != nil {
 		return
-	case 0:
-		ps = append(ps, s)
+	}
+	err = checkRange(addr1, addr2)
+	return
+}
+
+// ProcessAddrRange generates string IP prefix pattern from parsed IP range.
+// `addr1` is start IP. `addr2` is end IP.
+func ProcessAddrRange(addr1, addr2 netip.Addr) (ps []string, err error) {
+	if err = checkRange(addr1, addr2); err != nil {
 		return
 	}
-	ip1 := addr1.AsSlice()
-	ip2 := addr2.AsSlice()
-	var ipr []string
-	if addr1.Is4() {
-		prefixBlock := 0
-		for i := 0; i < 4; i++ {
-			if ip1[i] == ip2[i] {
-				prefixBlock++
-			} else {
-				break
-			}
-		}
-		carry := false
-		for i := 3; i >= prefixBlock; i-- {
-			if carry {
-				ip1[i]++
-			}
-			if i == prefixBlock {
-				break
-			}
-			vm := ip1[i]
-			if vm == 0 {
-				continue
-			}
-			for j := int(vm); j < 0x100; j++ {
-				addr := netip.AddrFrom4([4]byte(ip1))
-				rs := addr.String()
-				if i < 3 {
-					rs = rs[:len(rs)-1] + "*"
-				}
-				ps = append(ps, rs)
-				ip1[i]++
-			}
-			carry = true
-		}
-		borrow := false
-		for i := 3; i >= prefixBlock; i-- {
-			if borrow {
-				ip2[i]--
-			}
-			if i == prefixBlock {
-				break
-			}
-			vm := ip2[i]
-			if vm == 0xff {
-				continue
-			}
-			for j := int(vm); j >= 0; j-- {
-				addr := netip.AddrFrom4([4]byte(ip2))
-				rs := addr.String()
-				if i < 3 {
-					rs = rs[:len(rs)-3] + "*"
-				}
-				ps = append(ps, rs)
-				ip2[i]--
-			}
-			borrow = true
-		}
-		if prefixBlock > 0 && ip1[prefixBlock] == 0 && ip2[prefixBlock] == 0xff {
-			prefixBlock--
-		}
-		ipr = genV4(ip1, prefixBlock, ip1[prefixBlock], ip2[prefixBlock])
-	} else if addr1.Is6() {
-		is4In6 := addr1.Is4In6()
-		prefixBlock := 0
-		for i := 0; i < 8; i++ {
-			if beUint16(ip1, i) == beUint16(ip2, i) {
-				prefixBlock++
-			} else {
-				break
-			}
-		}
-		carry := false
-		for i := 7; i >= prefixBlock; i-- {
-			vm := beUint16(ip1, i)
-			if carry {
-				vm++
-				setbeUint16(ip1, i, vm)
-			}
-			if i == prefixBlock {
-				break
-			}
-			if vm == 0 {
-				continue
-			}
-			step := 1
-			for j := int(vm); j < 0x10000; j += step {
-				addr := netip.AddrFrom16([16]byte(ip1))
-				rs := addr.String()
-				isIP := true
-				if is4In6 {
-					if step == 1 && j&0xff == 0 {
-						step = 0x100
-					}
-					if step == 0x100 {
-						rs = rs[:len(rs)-1] + "*"
-					}
-				} else if i < 7 {
-					ipri := processPrefix(netip.PrefixFrom(addr, 16*(i+1)))
-					ps = append(ps, ipri...)
-					isIP = false
-				}
-				if isIP {
-					ps = append(ps, rs)
-				}
-				vm += uint16(step)
-				setbeUint16(ip1, i, vm)
-			}
-			carry = true
-		}
-		borrow := false
-		for i := 7; i >= prefixBlock; i-- {
-			vm := beUint16(ip2, i)
-			if borrow {
-				vm--
-				setbeUint16(ip2, i, vm)
-			}
-			if i == prefixBlock {
-				break
-			}
-			if vm == 0xffff {
-				continue
-			}
-			step := 1
-			for j := int(vm); j >= 0; j -= step {
-				addr := netip.AddrFrom16([16]byte(ip2))
-				rs := addr.String()
-				isIP := true
-				if is4In6 {
-					if step == 1 && j&0xff == 0xff {
-						step = 0x100
-					}
-					if step == 0x100 {
-						rs = rs[:len(rs)-3] + "*"
-					}
-				} else if i < 7 {
-					ipri := processPrefix(netip.PrefixFrom(addr, 16*(i+1)))
-					ps = append(ps, ipri...)
-					isIP = false
-				}
-				if isIP {
-					ps = append(ps, rs)
-				}
-				vm -= uint16(step)
-				setbeUint16(ip2, i, vm)
-			}
-			borrow = true
-		}
-		if prefixBlock > 0 && beUint16(ip1, prefixBlock) == 0 && beUint16(ip2, prefixBlock) == 0xffff {
-			prefixBlock--
+	return prefixesPatterns(addrRange{addr1, addr2}.prefixes()), nil
+}
+
+func checkRange(addr1, addr2 netip.Addr) error {
+	if !addr1.IsValid() || !addr2.IsValid() {
+		return fmt.Errorf("%w: %v - %v", ErrInvalidIP, addr1, addr2)
+	}
+	if addr1.Zone() != "" || addr2.Zone() != "" {
+		return fmt.Errorf("%w: zoned %v - %v", ErrInvalidIP, addr1, addr2)
+	}
+	if addr1.BitLen() != addr2.BitLen() {
+		return fmt.Errorf("%w: %v Vs %v", ErrMixedFamilies, addr1, addr2)
+	}
+	if addr1.Compare(addr2) > 0 {
+		return fmt.Errorf("%w: %v > %v", ErrReversedRange, addr1, addr2)
+	}
+	return nil
+}
+
+// processRange requires a range passed checkRange. It decomposes the range
+// into the least prefixes, and generates patterns of each.
+func processRange(addr1, addr2 netip.Addr, form IPv6Form, yield func(string) bool) bool {
+	sp := getPrefixes()
+	ps := addrRange{addr1, addr2}.appendPrefixes(*sp)
+	defer putPrefixes(sp, ps)
+	for _, p := range ps {
+		if !processPrefix(p, form, yield) {
+			return false
 		}
-		sv := beUint16(ip1, prefixBlock)
-		ev := beUint16(ip2, prefixBlock)
-		ipr = genV6(ip1, prefixBlock, sv, ev, is4In6)
 	}
-	ps = append(ps, ipr...)
-	return
+	return true
 }
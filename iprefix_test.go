@@ -6,7 +6,9 @@
 package iprefix
 
 import (
+	"errors"
 	"fmt"
+	"net/netip"
 	"sort"
 	"strings"
 	"testing"
@@ -155,7 +157,48 @@ func prepareExpected() {
 
 	t = Test{CIDR: "", Range: "::fffe:ffff:ffff-::2:1:0:0"}
 	{
-		t.Expected = []string{"::fffe:ffff:ffff", "::ffff.*", "::2:1:0:0", "::2:0:*", "::1:*"}
+		t.Expected = []string{"::fffe:ffff:ffff", "::ffff:*", "::2:1:0:0", "::2:0:*", "::1:*"}
+		test = append(test, t)
+	}
+
+	t = Test{CIDR: "0:0:0:1::/64", Range: "0:0:0:1::-::1:ffff:ffff:ffff:ffff"}
+	{
+		// 0:0:0:1::, ::1:5:6:7:8
+		t.Expected = []string{"::1:*", "0:0:0:1::"}
+		test = append(test, t)
+	}
+
+	t = Test{CIDR: "::ffff:0:0/96", Range: "::ffff:0.0.0.0-::ffff:255.255.255.255"}
+	{
+		t.Expected = []string{"::ffff:*"}
+		test = append(test, t)
+	}
+
+	t = Test{CIDR: "", Range: "10.253.255.0-10.255.255.255"}
+	{
+		t.Expected = []string{"10.253.255.*", "10.254.*", "10.255.*"}
+		test = append(test, t)
+	}
+
+	t = Test{CIDR: "", Range: "54.28.255.255-96.255.255.130"}
+	{
+		r := []string{"54.28.255.255"}
+		for i := 29; i < 256; i++ {
+			r = append(r, fmt.Sprintf("54.%d.*", i))
+		}
+		for i := 55; i < 96; i++ {
+			r = append(r, fmt.Sprintf("%d.*", i))
+		}
+		for i := 0; i < 255; i++ {
+			r = append(r, fmt.Sprintf("96.%d.*", i))
+		}
+		for i := 0; i < 255; i++ {
+			r = append(r, fmt.Sprintf("96.255.%d.*", i))
+		}
+		for i := 0; i <= 130; i++ {
+			r = append(r, fmt.Sprintf("96.255.255.%d", i))
+		}
+		t.Expected = r
 		test = append(test, t)
 	}
 
@@ -376,3 +419,173 @@ func TestProcessRange(t *testing.T) {
 		}
 	}
 }
+
+func TestProcessPrefix(t *testing.T) {
+	prepareExpected()
+	for _, mt := range test {
+		if len(mt.CIDR) == 0 {
+			continue
+		}
+		p, err := netip.ParsePrefix(mt.CIDR)
+		if err != nil {
+			continue
+		}
+		r := ProcessPrefix(p)
+		if d, ok := mt.Expected.([]string); ok && !validate(r, d) {
+			t.Error(mt.CIDR)
+		}
+	}
+
+	if r := ProcessPrefix(netip.Prefix{}); r != nil {
+		t.Error(r)
+	}
+	if r := AppendPrefixText(nil, netip.Prefix{}); len(r) != 0 {
+		t.Error(r)
+	}
+	if r := ProcessPrefixParallel(netip.Prefix{}, 2); r != nil {
+		t.Error(r)
+	}
+	for s := range IterPrefix(netip.Prefix{}) {
+		t.Error(s)
+	}
+}
+
+func TestPatternCount(t *testing.T) {
+	prepareExpected()
+	ss := []string{"0.0.0.0/0", "10.0.0.0/20", "::/0", "::/65", "::ffff:0:0/95", "::ffff:10.0.0.0/100", "1:0:0:4444::/71", "2001:db8::1/128"}
+	for _, mt := range test {
+		ss = append(ss, mt.CIDR)
+	}
+	for _, s := range ss {
+		p, err := netip.ParsePrefix(s)
+		if err != nil {
+			continue
+		}
+		if p.Bits() < 16 && p.Addr().Is6() {
+			// too many to generate, check the cached spellings only
+			if n := PatternCount(p); n < 1<<16 {
+				t.Error(s, n)
+			}
+			continue
+		}
+		if r := ProcessPrefix(p); PatternCount(p) != len(r) || cap(r) != len(r) {
+			t.Error(s, PatternCount(p), len(r), cap(r))
+		}
+	}
+	if n := PatternCount(netip.Prefix{}); n != 0 {
+		t.Error(n)
+	}
+}
+
+func TestProcessAddrRange(t *testing.T) {
+	prepareExpected()
+	for _, mt := range test {
+		if len(mt.Range) == 0 {
+			continue
+		}
+		rg := strings.Split(mt.Range, "-")
+		addr1, err1 := netip.ParseAddr(rg[0])
+		addr2, err2 := netip.ParseAddr(rg[1])
+		if err1 != nil || err2 != nil {
+			continue
+		}
+		r, err := ProcessAddrRange(addr1, addr2)
+		switch d := mt.Expected.(type) {
+		case []string:
+			if !validate(r, d) {
+				t.Error(mt.Range)
+			}
+		case error:
+			if err == nil {
+				t.Error(mt.Range)
+			}
+		}
+	}
+	if _, err := ProcessAddrRange(netip.Addr{}, netip.MustParseAddr("10.0.0.1")); err == nil {
+		t.Error("invalid IP")
+	}
+	if _, err := ProcessAddrRange(netip.MustParseAddr("10.0.0.1"), netip.MustParseAddr("::1")); err == nil {
+		t.Error("mixed types")
+	}
+	if _, err := ProcessAddrRange(netip.MustParseAddr("fe80::1%eth0"), netip.MustParseAddr("fe80::3%eth0")); !errors.Is(err, ErrInvalidIP) {
+		t.Error("zoned", err)
+	}
+}
+
+func TestProcessRangeZoned(t *testing.T) {
+	// the zones are dropped
+	tests := []struct {
+		s, e     string
+		expected string
+	}{
+		{"fe80::1%eth0", "fe80::3%eth0", "fe80::1 fe80::2 fe80::3"},
+		{"fe80::1%eth0", "fe80::1%eth0", "fe80::1"},
+		{"fe80::%eth0", "fe80::ffff%eth1", "fe80::*"},
+	}
+	for _, mt := range tests {
+		r, err := ProcessRange(mt.s, mt.e)
+		if err != nil || strings.Join(r, " ") != mt.expected {
+			t.Error(mt.s, mt.e, r, err)
+		}
+	}
+	if r, err := Process("fe80::1%eth0"); err != nil || strings.Join(r, " ") != "fe80::1" {
+		t.Error(r, err)
+	}
+}
+
+func TestProcessFunc(t *testing.T) {
+	var r []string
+	err := ProcessCIDRFunc("10.0.0.0/14", func(ps string) bool {
+		r = append(r, ps)
+		return len(r) < 2
+	})
+	if err != nil || !validate(r, []string{"10.0.*", "10.1.*"}) {
+		t.Error(r, err)
+	}
+
+	r = r[:0]
+	err = ProcessRangeFunc("10.0.0.254", "10.0.2.1", func(ps string) bool {
+		r = append(r, ps)
+		return true
+	})
+	if err != nil || !validate(r, []string{"10.0.0.254", "10.0.0.255", "10.0.2.1", "10.0.2.0", "10.0.1.*"}) {
+		t.Error(r, err)
+	}
+
+	if ProcessCIDRFunc("10.0.0.0/33", nil) == nil {
+		t.Error("10.0.0.0/33")
+	}
+	if ProcessRangeFunc("10.0.0.2", "10.0.0.1", nil) == nil {
+		t.Error("10.0.0.2-10.0.0.1")
+	}
+}
+
+func BenchmarkProcessCIDR4(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		ProcessCIDR("10.0.0.0/25")
+	}
+}
+
+func BenchmarkProcessCIDR6(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		ProcessCIDR("2001:db8::/104")
+	}
+}
+
+func BenchmarkProcessCIDR6Spellings(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		ProcessCIDR("1111:0:0:4444::/72")
+	}
+}
+
+func BenchmarkProcessCIDR4In6(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		ProcessCIDR("::ffff:10.0.0.0/121")
+	}
+}
+
+func BenchmarkProcessRange4(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		ProcessRange("10.0.0.3", "10.3.2.200")
+	}
+}
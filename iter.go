@@ -0,0 +1,48 @@
+// Copyright 2023-now by lifenjoiner. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE file.
+
+package iprefix
+
+import (
+	"iter"
+	"net/netip"
+)
+
+// IterPrefix lazily generates string IP prefix pattern from a parsed prefix.
+func IterPrefix(p netip.Prefix) iter.Seq[string] {
+	return func(yield func(string) bool) {
+		processPrefix(p, IPv6All, yield)
+	}
+}
+
+// IterCIDR lazily generates string IP prefix pattern from CIDR.
+// A parsing error is yielded once with an empty pattern.
+func IterCIDR(s string) iter.Seq2[string, error] {
+	return func(yield func(string, error) bool) {
+		p, err := parsePrefix(s)
+		if err != nil {
+			yield("", err)
+			return
+		}
+		processPrefix(p, IPv6All, func(ps string) bool {
+			return yield(ps, nil)
+		})
+	}
+}
+
+// IterRange lazily generates string IP prefix pattern from IP range.
+// `s` is start IP. `e` is end IP.
+// A parsing error is yielded once with an empty pattern.
+func IterRange(s, e string) iter.Seq2[string, error] {
+	return func(yield func(string, error) bool) {
+		addr1, addr2, err := parseRange(s, e)
+		if err != nil {
+			yield("", err)
+			return
+		}
+		processRange(addr1, addr2, IPv6All, func(ps string) bool {
+			return yield(ps, nil)
+		})
+	}
+}
@@ -0,0 +1,81 @@
+// Copyright 2023-now by lifenjoiner. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE file.
+
+package iprefix
+
+import (
+	"net/netip"
+	"strings"
+	"testing"
+)
+
+func TestIterCIDR(t *testing.T) {
+	prepareExpected()
+	for _, mt := range test {
+		if len(mt.CIDR) == 0 {
+			continue
+		}
+		var r []string
+		var err error
+		for ps, e := range IterCIDR(mt.CIDR) {
+			if e != nil {
+				err = e
+				break
+			}
+			r = append(r, ps)
+		}
+		switch d := mt.Expected.(type) {
+		case []string:
+			if !validate(r, d) {
+				t.Error(mt.CIDR)
+			}
+		case error:
+			if err == nil {
+				t.Error(mt.CIDR)
+			}
+		}
+	}
+}
+
+func TestIterRange(t *testing.T) {
+	prepareExpected()
+	for _, mt := range test {
+		if len(mt.Range) == 0 {
+			continue
+		}
+		rg := strings.Split(mt.Range, "-")
+		var r []string
+		var err error
+		for ps, e := range IterRange(rg[0], rg[1]) {
+			if e != nil {
+				err = e
+				break
+			}
+			r = append(r, ps)
+		}
+		switch d := mt.Expected.(type) {
+		case []string:
+			if !validate(r, d) {
+				t.Error(mt.Range)
+			}
+		case error:
+			if err == nil {
+				t.Error(mt.Range)
+			}
+		}
+	}
+}
+
+func TestIterBreak(t *testing.T) {
+	n := 0
+	for range IterPrefix(netip.MustParsePrefix("2001::/20")) {
+		n++
+		if n == 3 {
+			break
+		}
+	}
+	if n != 3 {
+		t.Error(n)
+	}
+}
@@ -0,0 +1,60 @@
+// Copyright 2023-now by lifenjoiner. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE file.
+
+package iprefix
+
+import "net/netip"
+
+// Matcher is a compiled set of patterns, matching an IP in the time of its bit
+// length, however many the patterns are.
+type Matcher struct {
+	t     Table[struct{}]
+	bloom *bloomFilter
+}
+
+// MatcherOption tunes the Matcher of Compile.
+type MatcherOption func(*matcherOptions)
+
+type matcherOptions struct {
+	bloomBits int
+}
+
+// Bloom layers a Bloom filter of `bitsPerPrefix` bits per prefix in front of
+// the trie, speeding up the IPs matching nothing, which are the most for high
+// QPS filtering. 10 bits make about 1% false positives per prefix length.
+func Bloom(bitsPerPrefix int) MatcherOption {
+	return func(o *matcherOptions) {
+		o.bloomBits = bitsPerPrefix
+	}
+}
+
+// Compile builds a Matcher from patterns, CIDRs, IP ranges `IP1-IP2` or IPs.
+func Compile(patterns []string, opts ...MatcherOption) (*Matcher, error) {
+	set, err := NewPatternSet(patterns)
+	if err != nil {
+		return nil, err
+	}
+	var o matcherOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	m := &Matcher{}
+	ps := rangesPrefixes(set.ranges)
+	for _, p := range ps {
+		m.t.Insert(p, struct{}{})
+	}
+	if o.bloomBits > 0 {
+		m.bloom = newBloomFilter(ps, o.bloomBits)
+	}
+	return m, nil
+}
+
+// Contains reports whether `addr` matches. IPv4 and 4in6 are different.
+func (m *Matcher) Contains(addr netip.Addr) bool {
+	if !addr.IsValid() || m.bloom != nil && !m.bloom.mayContain(addr) {
+		return false
+	}
+	_, ok := m.t.Lookup(addr)
+	return ok
+}
@@ -0,0 +1,124 @@
+// Copyright 2023-now by lifenjoiner. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE file.
+
+package iprefix
+
+import (
+	"math/rand"
+	"net/netip"
+	"testing"
+)
+
+func TestMatcher(t *testing.T) {
+	patterns := []string{"10.*", "192.168.1.*", "172.16.0.0/12", "8.8.8.8", "2001:db8:*", "::ffff:1.2.3.*", "::/128"}
+	m, err := Compile(patterns)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		addr     string
+		expected bool
+	}{
+		{"10.1.2.3", true},
+		{"11.0.0.0", false},
+		{"192.168.1.255", true},
+		{"192.168.2.0", false},
+		{"172.31.255.255", true},
+		{"172.32.0.0", false},
+		{"8.8.8.8", true},
+		{"8.8.8.9", false},
+		{"2001:db8::1", true},
+		{"2001:db9::1", false},
+		{"::ffff:1.2.3.4", true},
+		{"1.2.3.4", false},
+		{"::ffff:10.0.0.1", false},
+		{"::", true},
+		{"::1", false},
+	}
+	for _, mt := range tests {
+		if r := m.Contains(netip.MustParseAddr(mt.addr)); r != mt.expected {
+			t.Error(mt.addr, r)
+		}
+	}
+	if m.Contains(netip.Addr{}) {
+		t.Error("zero Addr")
+	}
+
+	all, _ := Compile([]string{"0.0.0.0/0"})
+	if !all.Contains(netip.MustParseAddr("1.2.3.4")) || all.Contains(netip.MustParseAddr("::1")) {
+		t.Error("0.0.0.0/0")
+	}
+	if _, err = Compile([]string{"x"}); err == nil {
+		t.Error("x")
+	}
+
+	set, _ := NewPatternSet(patterns)
+	rnd := rand.New(rand.NewSource(1))
+	for i := 0; i < 10000; i++ {
+		addr := netip.AddrFrom4([4]byte{10 + byte(rnd.Intn(3)), byte(rnd.Intn(256)), byte(rnd.Intn(256)), byte(rnd.Intn(256))})
+		if i%2 == 1 {
+			addr = netip.AddrFrom4([4]byte{192, 168, byte(rnd.Intn(3)), byte(rnd.Intn(256))})
+		}
+		if m.Contains(addr) != set.Contains(addr) {
+			t.Error(addr)
+		}
+	}
+}
+
+func TestMatcherBloom(t *testing.T) {
+	var patterns []string
+	rnd := rand.New(rand.NewSource(1))
+	for i := 0; i < 1000; i++ {
+		p := netip.PrefixFrom(netip.AddrFrom4([4]byte{byte(rnd.Intn(256)), byte(rnd.Intn(256)), byte(rnd.Intn(256)), 0}), 16+rnd.Intn(17))
+		patterns = append(patterns, p.Masked().String())
+	}
+	patterns = append(patterns, "2001:db8:*", "::1")
+	m, _ := Compile(patterns)
+	mb, err := Compile(patterns, Bloom(10))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 100000; i++ {
+		addr := netip.AddrFrom4([4]byte{byte(rnd.Intn(256)), byte(rnd.Intn(256)), byte(rnd.Intn(256)), byte(rnd.Intn(256))})
+		if mb.Contains(addr) != m.Contains(addr) {
+			t.Error(addr)
+		}
+	}
+	for _, s := range []string{"2001:db8::1", "::1"} {
+		if !mb.Contains(netip.MustParseAddr(s)) {
+			t.Error(s)
+		}
+	}
+	for _, s := range []string{"2001:db9::1", "::2"} {
+		if mb.Contains(netip.MustParseAddr(s)) {
+			t.Error(s)
+		}
+	}
+}
+
+func benchmarkMatcher(b *testing.B, opts ...MatcherOption) {
+	var patterns []string
+	rnd := rand.New(rand.NewSource(1))
+	for i := 0; i < 10000; i++ {
+		p := netip.PrefixFrom(netip.AddrFrom4([4]byte{byte(rnd.Intn(256)), byte(rnd.Intn(256)), byte(rnd.Intn(256)), 0}), 24)
+		patterns = append(patterns, p.String())
+	}
+	m, _ := Compile(patterns, opts...)
+	addrs := make([]netip.Addr, 1024)
+	for i := range addrs {
+		addrs[i] = netip.AddrFrom4([4]byte{byte(rnd.Intn(256)), byte(rnd.Intn(256)), byte(rnd.Intn(256)), byte(rnd.Intn(256))})
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		m.Contains(addrs[i%len(addrs)])
+	}
+}
+
+func BenchmarkMatcher(b *testing.B) {
+	benchmarkMatcher(b)
+}
+
+func BenchmarkMatcherBloom(b *testing.B) {
+	benchmarkMatcher(b, Bloom(10))
+}
@@ -0,0 +1,56 @@
+// Copyright 2023-now by lifenjoiner. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE file.
+
+package iprefix
+
+import (
+	"fmt"
+	"net"
+	"net/netip"
+)
+
+// ipNetPrefix converts a net.IPNet to a prefix. The mask length tells the
+// family, since the IPv4 IP may be held in 16 bytes.
+func ipNetPrefix(n *net.IPNet) (p netip.Prefix, err error) {
+	if n == nil {
+		err = fmt.Errorf("%w: IPNet %v", ErrInvalidCIDR, n)
+		return
+	}
+	ip := n.IP
+	if len(n.Mask) == net.IPv4len {
+		ip = ip.To4()
+	}
+	ones, bits := n.Mask.Size()
+	addr, ok := netip.AddrFromSlice(ip)
+	if !ok || bits == 0 || bits != addr.BitLen() {
+		err = fmt.Errorf("%w: IPNet %v", ErrInvalidCIDR, n)
+		return
+	}
+	return netip.PrefixFrom(addr, ones), nil
+}
+
+// ipAddr converts a net.IP to an address, the IPv4-mapped 16 bytes ones to IPv4.
+func ipAddr(ip net.IP) (addr netip.Addr) {
+	if ip4 := ip.To4(); ip4 != nil {
+		ip = ip4
+	}
+	addr, _ = netip.AddrFromSlice(ip)
+	return
+}
+
+// ProcessIPNet generates string IP prefix pattern from a net.IPNet.
+func ProcessIPNet(n *net.IPNet) (ps []string, err error) {
+	p, err := ipNetPrefix(n)
+	if err != nil {
+		return
+	}
+	return ProcessPrefix(p), nil
+}
+
+// ProcessIPRange generates string IP prefix pattern from a net.IP range.
+// `ip1` is start IP. `ip2` is end IP. IPv4 IPs held in 16 bytes, as net.ParseIP
+// returns, are taken as IPv4.
+func ProcessIPRange(ip1, ip2 net.IP) (ps []string, err error) {
+	return ProcessAddrRange(ipAddr(ip1), ipAddr(ip2))
+}
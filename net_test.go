@@ -0,0 +1,53 @@
+// Copyright 2023-now by lifenjoiner. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE file.
+
+package iprefix
+
+import (
+	"net"
+	"testing"
+)
+
+func TestProcessIPNet(t *testing.T) {
+	tests := []struct {
+		n        *net.IPNet
+		expected []string
+	}{
+		{&net.IPNet{IP: net.ParseIP("10.1.0.0"), Mask: net.CIDRMask(16, 32)}, []string{"10.1.*"}},
+		{&net.IPNet{IP: net.IPv4(10, 1, 0, 0).To4(), Mask: net.CIDRMask(16, 32)}, []string{"10.1.*"}},
+		{&net.IPNet{IP: net.ParseIP("2001:db8::"), Mask: net.CIDRMask(32, 128)}, []string{"2001:db8:*"}},
+		{&net.IPNet{IP: net.ParseIP("::ffff:10.1.0.0"), Mask: net.CIDRMask(112, 128)}, []string{"::ffff:10.1.*"}},
+		{&net.IPNet{IP: net.ParseIP("2001:db8::"), Mask: net.CIDRMask(16, 32)}, nil},
+		{&net.IPNet{IP: net.ParseIP("10.1.0.0"), Mask: net.IPMask{255, 0, 255, 0}}, nil},
+		{nil, nil},
+	}
+	for _, mt := range tests {
+		ps, err := ProcessIPNet(mt.n)
+		if mt.expected == nil {
+			if err == nil {
+				t.Error(mt.n, ps)
+			}
+		} else if err != nil || !validate(ps, mt.expected) {
+			t.Error(mt.n, ps, err)
+		}
+	}
+
+	_, n, _ := net.ParseCIDR("10.1.0.0/16")
+	if ps, err := ProcessIPNet(n); err != nil || !validate(ps, []string{"10.1.*"}) {
+		t.Error(n, ps, err)
+	}
+}
+
+func TestProcessIPRange(t *testing.T) {
+	ps, err := ProcessIPRange(net.ParseIP("10.0.0.0"), net.IPv4(10, 0, 1, 255).To4())
+	if err != nil || !validate(ps, []string{"10.0.0.*", "10.0.1.*"}) {
+		t.Error(ps, err)
+	}
+	if ps, err = ProcessIPRange(net.ParseIP("10.0.0.0"), net.ParseIP("::1")); err == nil {
+		t.Error(ps)
+	}
+	if ps, err = ProcessIPRange(nil, net.ParseIP("10.0.0.1")); err == nil {
+		t.Error(ps)
+	}
+}
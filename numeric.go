@@ -0,0 +1,60 @@
+// Copyright 2023-now by lifenjoiner. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE file.
+
+package iprefix
+
+import (
+	"fmt"
+	"math/big"
+	"net/netip"
+)
+
+// Uint32Addr returns the IPv4 address of a number.
+func Uint32Addr(v uint32) netip.Addr {
+	return netip.AddrFrom4([4]byte{byte(v >> 24), byte(v >> 16), byte(v >> 8), byte(v)})
+}
+
+// BigAddr returns the address of a number, `bitLen` is 32 for IPv4 or 128 for
+// IPv6.
+func BigAddr(v *big.Int, bitLen int) (addr netip.Addr, err error) {
+	if v == nil || v.Sign() < 0 || v.BitLen() > bitLen || bitLen != 32 && bitLen != 128 {
+		err = fmt.Errorf("%w: %d-bit number %v", ErrInvalidIP, bitLen, v)
+		return
+	}
+	ip := make([]byte, bitLen/8)
+	v.FillBytes(ip)
+	addr, _ = netip.AddrFromSlice(ip)
+	return
+}
+
+// AddrBig returns the number of an address.
+func AddrBig(addr netip.Addr) *big.Int {
+	return new(big.Int).SetBytes(addr.AsSlice())
+}
+
+// ProcessUint32Range generates string IP prefix pattern from an IPv4 range of
+// numbers.
+func ProcessUint32Range(start, end uint32) ([]string, error) {
+	return ProcessAddrRange(Uint32Addr(start), Uint32Addr(end))
+}
+
+// ProcessBytesRange generates string IP prefix pattern from an IPv6 range of
+// 16 bytes numbers.
+func ProcessBytesRange(start, end [16]byte) ([]string, error) {
+	return ProcessAddrRange(netip.AddrFrom16(start), netip.AddrFrom16(end))
+}
+
+// ProcessBigRange generates string IP prefix pattern from a range of numbers,
+// `bitLen` is 32 for IPv4 or 128 for IPv6.
+func ProcessBigRange(start, end *big.Int, bitLen int) (ps []string, err error) {
+	addr1, err := BigAddr(start, bitLen)
+	if err != nil {
+		return
+	}
+	addr2, err := BigAddr(end, bitLen)
+	if err != nil {
+		return
+	}
+	return ProcessAddrRange(addr1, addr2)
+}
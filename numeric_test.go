@@ -0,0 +1,75 @@
+// Copyright 2023-now by lifenjoiner. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE file.
+
+package iprefix
+
+import (
+	"math/big"
+	"net/netip"
+	"testing"
+)
+
+func TestBigAddr(t *testing.T) {
+	tests := []struct {
+		v        string
+		bitLen   int
+		expected string
+	}{
+		{"167772161", 32, "10.0.0.1"},
+		{"4294967295", 32, "255.255.255.255"},
+		{"4294967296", 32, ""},
+		{"-1", 32, ""},
+		{"1", 64, ""},
+		{"1", 128, "::1"},
+		{"42540766411282592856903984951653826561", 128, "2001:db8::1"},
+	}
+	for _, mt := range tests {
+		v, _ := new(big.Int).SetString(mt.v, 10)
+		addr, err := BigAddr(v, mt.bitLen)
+		if mt.expected == "" {
+			if err == nil {
+				t.Error(mt.v, addr)
+			}
+			continue
+		}
+		if err != nil || addr.String() != mt.expected {
+			t.Error(mt.v, addr, err)
+		}
+		if AddrBig(addr).Cmp(v) != 0 {
+			t.Error(mt.v, AddrBig(addr))
+		}
+	}
+	if _, err := BigAddr(nil, 32); err == nil {
+		t.Error("nil")
+	}
+}
+
+func TestProcessNumericRange(t *testing.T) {
+	if addr := Uint32Addr(0x0a000102); addr.String() != "10.0.1.2" {
+		t.Error(addr)
+	}
+
+	ps, err := ProcessUint32Range(0x0a000000, 0x0a0001ff)
+	if err != nil || !validate(ps, []string{"10.0.0.*", "10.0.1.*"}) {
+		t.Error(ps, err)
+	}
+	if ps, err = ProcessUint32Range(2, 1); err == nil {
+		t.Error(ps)
+	}
+
+	start := netip.MustParseAddr("2001:db8::").As16()
+	end := netip.MustParseAddr("2001:db8:1:ffff:ffff:ffff:ffff:ffff").As16()
+	ps, err = ProcessBytesRange(start, end)
+	if err != nil || !validate(ps, []string{"2001:db8:0:*", "2001:db8::*", "2001:db8:1:*"}) {
+		t.Error(ps, err)
+	}
+
+	ps, err = ProcessBigRange(big.NewInt(0x0a000000), big.NewInt(0x0a0001ff), 32)
+	if err != nil || !validate(ps, []string{"10.0.0.*", "10.0.1.*"}) {
+		t.Error(ps, err)
+	}
+	if ps, err = ProcessBigRange(big.NewInt(0), big.NewInt(-1), 32); err == nil {
+		t.Error(ps)
+	}
+}
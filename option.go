@@ -0,0 +1,367 @@
+// Copyright 2023-now by lifenjoiner. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE file.
+
+package iprefix
+
+import (
+	"net/netip"
+	"sort"
+	"strings"
+)
+
+// Order is the order of the generated patterns.
+type Order int
+
+const (
+	// OrderGenerated keeps the generating order.
+	OrderGenerated Order = iota
+	// OrderLexical sorts patterns as strings.
+	OrderLexical
+	// OrderAddress sorts patterns by address, IPv4 before IPv6, the spellings
+	// of the same prefix together.
+	OrderAddress
+)
+
+// IPv6Form is the spelling of the IPv6 patterns.
+type IPv6Form int
+
+const (
+	// IPv6All emits all the spellings that the canonical IPv6 texts of the
+	// prefix start with.
+	IPv6All IPv6Form = iota
+	// IPv6Canonical emits only one spelling per prefix, the canonical text
+	// of its IPs of nonzero trailing blocks, which ParsePattern reads back
+	// to the prefix. It gives up the coverage: the IPs whose texts compress
+	// other zero blocks don't match it, like `::1` for `0:*` of `::/16`.
+	IPv6Canonical
+	// IPv6Expanded writes all the blocks without zero compression.
+	IPv6Expanded
+	// IPv6Padded writes all the blocks zero-padded to 4 digits.
+	IPv6Padded
+)
+
+// Syntax is the syntax of the patterns.
+type Syntax int
+
+const (
+	// SyntaxWildcard ends patterns with the wildcard.
+	SyntaxWildcard Syntax = iota
+	// SyntaxGlob writes path.Match patterns, with digit classes for the
+	// partial IPv4 octets, so unaligned prefixes don't expand to every octet.
+	SyntaxGlob
+)
+
+// IPv4Mapping is the conversion between IPv4 and IPv4-mapped IPv6 (4in6).
+type IPv4Mapping int
+
+const (
+	// IPv4Keep keeps the family of the input.
+	IPv4Keep IPv4Mapping = iota
+	// IPv4Unmapped converts 4in6 to IPv4, like `::ffff:10.0.0.0/104` to `10.*`.
+	IPv4Unmapped
+	// IPv4Mapped converts IPv4 to 4in6, like `10.0.0.0/8` to `::ffff:10.*`.
+	IPv4Mapped
+)
+
+type options struct {
+	wildcard string
+	order    Order
+	limit    int
+	max      int
+	minimize bool
+	form     IPv6Form
+	nibble   bool
+	syntax   Syntax
+	mapping  IPv4Mapping
+	embed    []embedding
+	exclude  []addrRange
+}
+
+// Option tunes the output of Process.
+type Option func(*options)
+
+// Wildcard sets the wildcard string, default is "*".
+func Wildcard(w string) Option {
+	return func(o *options) {
+		o.wildcard = w
+	}
+}
+
+// Sort sets the order of the patterns.
+func Sort(order Order) Option {
+	return func(o *options) {
+		o.order = order
+	}
+}
+
+// Limit caps the count of the patterns, 0 means no limit.
+func Limit(n int) Option {
+	return func(o *options) {
+		o.limit = n
+	}
+}
+
+// MaxPatterns fails the generation with ErrTooManyPatterns once the patterns
+// exceed `n`, instead of truncating them as Limit does, 0 means no cap.
+func MaxPatterns(n int) Option {
+	return func(o *options) {
+		o.max = n
+	}
+}
+
+// Form sets the spelling of the IPv6 patterns.
+func Form(form IPv6Form) Option {
+	return func(o *options) {
+		o.form = form
+	}
+}
+
+// Minimize merges the covered IPs before generating, so the patterns are the
+// least and never overlap, even for overlapping inputs.
+func Minimize() Option {
+	return func(o *options) {
+		o.minimize = true
+	}
+}
+
+// Nibble splits IPv6 prefixes at nibbles instead of hextets. Nibbles are only
+// textual prefixes of fixed width hextets, so it implies IPv6Padded, whatever
+// Form sets before or after.
+func Nibble() Option {
+	return func(o *options) {
+		o.nibble = true
+	}
+}
+
+// Output sets the syntax of the patterns.
+func Output(syntax Syntax) Option {
+	return func(o *options) {
+		o.syntax = syntax
+	}
+}
+
+// MapIPv4 converts between IPv4 and 4in6, to match the family the consumer
+// logs in. The exclusions apply before.
+func MapIPv4(m IPv4Mapping) Option {
+	return func(o *options) {
+		o.mapping = m
+	}
+}
+
+// NAT64 emits the IPv4 patterns of the IPs embedded in the NAT64 `prefix`, like
+// NAT64WellKnown, instead of the IPv6 ones, or alongside if `alongside`. Only
+// /96 prefixes are supported, the others are ignored.
+func NAT64(prefix netip.Prefix, alongside bool) Option {
+	return func(o *options) {
+		if prefix.Bits() == 96 && prefix.Addr().Is6() {
+			o.embed = append(o.embed, embedding{prefixRange(prefix), 96, alongside})
+		}
+	}
+}
+
+// Decode6to4 emits the IPv4 patterns of the IPs embedded in the 6to4 prefix
+// instead of the IPv6 ones, or alongside if `alongside`.
+func Decode6to4(alongside bool) Option {
+	return func(o *options) {
+		o.embed = append(o.embed, embedding{prefixRange(SixToFour), 16, alongside})
+	}
+}
+
+// ExcludeReserved excludes the special-purpose address space not globally
+// routable, like private, shared, loopback, link-local, documentation and
+// multicast ones.
+func ExcludeReserved() Option {
+	return func(o *options) {
+		o.exclude = append(o.exclude, reservedRanges...)
+	}
+}
+
+// Exclude excludes the IPs of the prefixes.
+func Exclude(ps ...netip.Prefix) Option {
+	return func(o *options) {
+		for _, p := range ps {
+			o.exclude = append(o.exclude, prefixRange(p))
+		}
+	}
+}
+
+func newOptions(opts []Option) *options {
+	o := &options{wildcard: "*"}
+	for _, opt := range opts {
+		opt(o)
+	}
+	if o.nibble {
+		o.form = IPv6Padded
+	}
+	o.exclude = mergeRanges(o.exclude)
+	return o
+}
+
+// generator parses a pattern, a CIDR, an IP range `IP1-IP2`, an address and
+// wildcard mask pair or an IP, the same way whether decomposing or not.
+func (o *options) generator(s string) (gen func(yield func(string) bool) bool, err error) {
+	r, err := parseEntry(s)
+	if err != nil {
+		return
+	}
+	if o.decompose() {
+		return o.rangesGenerator([]addrRange{r}), nil
+	}
+	return func(yield func(string) bool) bool {
+		return processRange(r.from, r.to, o.form, yield)
+	}, nil
+}
+
+// decompose reports whether the entries go through the prefixes in order.
+func (o *options) decompose() bool {
+	return o.minimize || o.order == OrderAddress || o.form >= IPv6Expanded || o.syntax != SyntaxWildcard ||
+		len(o.exclude) > 0 || o.mapping != IPv4Keep || len(o.embed) > 0
+}
+
+// ranges removes the excluded ones from merged ranges, and maps the left.
+func (o *options) ranges(rs []addrRange) []addrRange {
+	if len(o.exclude) > 0 {
+		rs = subtractRanges(rs, o.exclude)
+	}
+	if o.mapping != IPv4Keep {
+		rs = mapRanges(rs, o.mapping)
+	}
+	for _, e := range o.embed {
+		rs = e.ranges(rs)
+	}
+	return rs
+}
+
+// patterns generates Patterns of a prefix as the options tune.
+func (o *options) patterns(p netip.Prefix, yield func(Pattern) bool) bool {
+	addr := p.Addr()
+	family := 6
+	if addr.Is4() {
+		family = 4
+	}
+	if o.syntax == SyntaxGlob && (addr.Is4() || addr.Is4In6() && p.Bits() >= 96) {
+		return globPrefix(p, func(s string) bool {
+			return yield(Pattern{s, p.Masked(), family, true})
+		})
+	}
+	return blocks(p, o.nibble, func(b netip.Prefix) bool {
+		wildcard := b.Bits() < addr.BitLen()
+		if o.form >= IPv6Expanded {
+			return yield(Pattern{formatExpanded(b, o.form == IPv6Padded), b, family, wildcard})
+		}
+		return processPrefix(b, o.form, func(s string) bool {
+			return yield(Pattern{s, b, family, wildcard})
+		})
+	})
+}
+
+// processPrefix generates patterns of a prefix as the options tune.
+func (o *options) processPrefix(p netip.Prefix, yield func(string) bool) bool {
+	return o.patterns(p, func(pt Pattern) bool {
+		return yield(pt.Text)
+	})
+}
+
+// rangesGenerator generates patterns of merged ranges.
+func (o *options) rangesGenerator(rs []addrRange) func(yield func(string) bool) bool {
+	return func(yield func(string) bool) bool {
+		for _, r := range o.ranges(rs) {
+			for _, p := range r.prefixes() {
+				if !o.processPrefix(p, yield) {
+					return false
+				}
+			}
+		}
+		return true
+	}
+}
+
+// more reports whether to generate more patterns after `n` ones, or the
+// error of too many.
+func (o *options) more(n int) (bool, error) {
+	if o.max > 0 && n > o.max {
+		return false, ErrTooManyPatterns
+	}
+	return o.order == OrderLexical || o.limit <= 0 || n < o.limit, nil
+}
+
+// output collects the patterns of the generator as the options tune.
+func (o *options) output(gen func(yield func(string) bool) bool) (ps []string, err error) {
+	gen(func(p string) bool {
+		ps = append(ps, p)
+		var more bool
+		more, err = o.more(len(ps))
+		return more
+	})
+	if err != nil {
+		return nil, err
+	}
+	if o.order == OrderLexical {
+		sort.Strings(ps)
+	}
+	if o.limit > 0 && len(ps) > o.limit {
+		ps = ps[:o.limit]
+	}
+	for i, p := range ps {
+		ps[i] = o.text(p)
+	}
+	return
+}
+
+// text replaces the wildcard of a pattern as the options tune.
+func (o *options) text(p string) string {
+	if o.wildcard == "*" {
+		return p
+	}
+	return strings.Replace(p, "*", o.wildcard, 1)
+}
+
+// Process generates string IP prefix pattern from a pattern, a CIDR, an IP
+// range `IP1-IP2` or an IP, tuned by `opts`.
+func Process(s string, opts ...Option) (ps []string, err error) {
+	o := newOptions(opts)
+	gen, err := o.generator(s)
+	if err != nil {
+		return
+	}
+	return o.output(gen)
+}
+
+// ProcessPatterns generates Patterns from a CIDR, an IP range `IP1-IP2` or an
+// IP, tuned by `opts`. They are in the order of address, unless sorted
+// otherwise.
+func ProcessPatterns(s string, opts ...Option) (pts []Pattern, err error) {
+	o := newOptions(opts)
+	r, err := parseEntry(s)
+	if err != nil {
+		return
+	}
+	for _, p := range rangesPrefixes(o.ranges([]addrRange{r})) {
+		if !o.patterns(p, func(pt Pattern) bool {
+			pts = append(pts, pt)
+			var more bool
+			more, err = o.more(len(pts))
+			return more
+		}) {
+			break
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	if o.order == OrderLexical {
+		sort.SliceStable(pts, func(i, j int) bool {
+			return pts[i].Text < pts[j].Text
+		})
+	}
+	if o.limit > 0 && len(pts) > o.limit {
+		pts = pts[:o.limit]
+	}
+	if o.wildcard != "*" {
+		for i := range pts {
+			pts[i].Text = strings.Replace(pts[i].Text, "*", o.wildcard, 1)
+		}
+	}
+	return
+}
@@ -0,0 +1,224 @@
+// Copyright 2023-now by lifenjoiner. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE file.
+
+package iprefix
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+	"strings"
+	"testing"
+)
+
+func TestProcess(t *testing.T) {
+	tests := []struct {
+		s        string
+		opts     []Option
+		expected []string
+	}{
+		{"10.0.0.0/15", nil, []string{"10.0.*", "10.1.*"}},
+		{"10.0.0.254-10.0.1.255", nil, []string{"10.0.0.254", "10.0.0.255", "10.0.1.*"}},
+		{"10.0.0.1", nil, []string{"10.0.0.1"}},
+		{"2001:20::/111", []Option{Wildcard("%")}, []string{"2001:20::%", "2001:20::1:%"}},
+		{"::ffff:ffff-::2:0:0", []Option{Form(IPv6Canonical), Sort(OrderAddress)}, []string{"::ffff:ffff", "::1:*", "::2:0:0"}},
+		{"1111::-1111:0:ffff:ffff:ffff:ffff:ffff:ffff", []Option{Form(IPv6Canonical), Minimize()}, []string{"1111:0:*"}},
+		{"10.0.0.254-10.0.1.255", []Option{Sort(OrderLexical), Limit(2)}, []string{"10.0.0.254", "10.0.0.255"}},
+		{"10.0.0.0/14", []Option{Limit(3)}, []string{"10.0.*", "10.1.*", "10.2.*"}},
+		{"10.0.254.255-10.2.2.0", []Option{Sort(OrderAddress)}, []string{"10.0.254.255", "10.0.255.*", "10.1.*", "10.2.0.*", "10.2.1.*", "10.2.2.0"}},
+		{"10.0.0.0/12", []Option{Sort(OrderAddress), Limit(3)}, []string{"10.0.*", "10.1.*", "10.2.*"}},
+		{"10.0.0.0/15", []Option{Minimize()}, []string{"10.0.*", "10.1.*"}},
+		{"10.0.0.0-10.1.255.255", []Option{Minimize()}, []string{"10.0.*", "10.1.*"}},
+		{"0:0:3333::/64", []Option{Form(IPv6Canonical)}, []string{"::3333:0:*"}},
+		{"0:0:3333::/64", []Option{Sort(OrderAddress)}, []string{"::3333:0:*", "0:0:3333::*", "0:0:3333:0:*"}},
+		{"10.*", nil, []string{"10.*"}},
+		{"10.*", []Option{Minimize()}, []string{"10.*"}},
+		{"10.1.*", []Option{Form(IPv6Canonical)}, []string{"10.1.*"}},
+	}
+	for _, mt := range tests {
+		r, err := Process(mt.s, mt.opts...)
+		if err != nil || strings.Join(r, "\n") != strings.Join(mt.expected, "\n") {
+			t.Error(mt.s, r, err)
+		}
+	}
+
+	for _, s := range []string{"10.0.0.0/33", "10.0.0.2-10.0.0.1", "10.0.0.256", "10.0.0.1-::1"} {
+		if _, err := Process(s); err == nil {
+			t.Error(s)
+		}
+	}
+}
+
+func TestIPv6Canonical(t *testing.T) {
+	// one spelling per prefix, read back to it, not matching all its IPs
+	for _, mt := range []struct {
+		cidr      string
+		expected  string
+		unmatched string
+	}{
+		{"::/16", "0:*", "::1"},
+		{"1111::/32", "1111:0:*", "1111::1"},
+		{"0:0:3333::/64", "::3333:0:*", "0:0:3333::1"},
+		{"1111:0:0:4444::/64", "1111::4444:*", "1111:0:0:4444::1"},
+	} {
+		ps, err := Process(mt.cidr, Form(IPv6Canonical))
+		if err != nil || len(ps) != 1 || ps[0] != mt.expected {
+			t.Error(mt.cidr, ps, err)
+			continue
+		}
+		if p, err := ParsePattern(ps[0]); err != nil || p.String() != mt.cidr {
+			t.Error(mt.cidr, p, err)
+		}
+		if strings.HasPrefix(mt.unmatched, strings.TrimSuffix(ps[0], "*")) {
+			t.Error(mt.cidr, mt.unmatched)
+		}
+	}
+}
+
+func TestProcessPatterns(t *testing.T) {
+	tests := []struct {
+		s        string
+		opts     []Option
+		expected string
+	}{
+		{"10.0.0.254-10.0.1.255", nil, "[{10.0.0.254 10.0.0.254/32 4 false} {10.0.0.255 10.0.0.255/32 4 false} {10.0.1.* 10.0.1.0/24 4 true}]"},
+		{"1111::/31", nil, "[{1111:0:* 1111::/32 6 true} {1111::* 1111::/32 6 true} {1111:1:* 1111:1::/32 6 true}]"},
+		{"1111::/31", []Option{Form(IPv6Canonical), Wildcard("%")}, "[{1111:0:% 1111::/32 6 true} {1111:1:% 1111:1::/32 6 true}]"},
+		{"10.0.0.16/28", []Option{Output(SyntaxGlob), Limit(1)}, "[{10.0.0.1[6-9] 10.0.0.16/28 4 true}]"},
+		{"::ffff:10.0.0.0/111", []Option{Sort(OrderLexical)}, "[{::ffff:10.0.* ::ffff:10.0.0.0/112 6 true} {::ffff:10.1.* ::ffff:10.1.0.0/112 6 true}]"},
+	}
+	for _, mt := range tests {
+		r, err := ProcessPatterns(mt.s, mt.opts...)
+		if err != nil || fmt.Sprint(r) != mt.expected {
+			t.Error(mt.s, r, err)
+		}
+	}
+	if _, err := ProcessPatterns("10.0.0.0/33"); err == nil {
+		t.Error("10.0.0.0/33")
+	}
+}
+
+func TestExcludeReserved(t *testing.T) {
+	tests := []struct {
+		s        string
+		expected []string
+	}{
+		{"10.0.0.0/7", []string{"11.*"}},
+		{"192.168.0.0-192.169.0.255", []string{"192.169.0.*"}},
+		{"198.18.0.0/15", nil},
+		{"127.0.0.1", nil},
+		{"2001:db8::/31", []string{"2001:db9:*"}},
+		{"fe00::/8", []string{"fe00:*", "fe01:*"}},
+		// as Classify, the 4in6 ones are of the IPv4 ones they map
+		{"::ffff:10.0.0.0/103", []string{"::ffff:11.*"}},
+		{"::ffff:8.8.8.0/120", []string{"::ffff:8.8.8.*"}},
+		{"::ffff:192.168.1.0/120", nil},
+	}
+	for _, mt := range tests {
+		r, err := Process(mt.s, ExcludeReserved(), Limit(2))
+		if err != nil || strings.Join(r, " ") != strings.Join(mt.expected, " ") {
+			t.Error(mt.s, r, err)
+		}
+	}
+	r, _ := Process("198.0.0.0/8", ExcludeReserved())
+	if len(r) != 508 {
+		t.Error(len(r))
+	}
+	pts, _ := ProcessPatterns("172.0.0.0/8", ExcludeReserved())
+	if len(pts) != 240 {
+		t.Error(len(pts))
+	}
+	for _, pt := range pts {
+		if c := pt.Class(); c != ClassGlobal {
+			t.Error(pt.Text, c)
+		}
+	}
+	pts, _ = ProcessPatterns("::ffff:172.0.0.0/104", ExcludeReserved())
+	if len(pts) != 240 {
+		t.Error(len(pts))
+	}
+	for _, pt := range pts {
+		if c := pt.Class(); c != ClassGlobal {
+			t.Error(pt.Text, c)
+		}
+	}
+}
+
+func TestExclude(t *testing.T) {
+	x := []netip.Prefix{netip.MustParsePrefix("10.1.0.0/16"), netip.MustParsePrefix("10.2.0.0/15")}
+	tests := []struct {
+		s        string
+		opts     []Option
+		expected []string
+	}{
+		{"10.0.0.0/14", nil, []string{"10.0.*"}},
+		{"10.1.2.0/24", nil, nil},
+		{"9.255.255.255-10.1.0.0", nil, []string{"9.255.255.255", "10.0.*"}},
+		{"2001:db8::/32", nil, []string{"2001:db8:*"}},
+		{"10.0.0.0/14", []Option{ExcludeReserved()}, nil},
+	}
+	for _, mt := range tests {
+		r, err := Process(mt.s, append(mt.opts, Exclude(x...))...)
+		if err != nil || strings.Join(r, " ") != strings.Join(mt.expected, " ") {
+			t.Error(mt.s, r, err)
+		}
+	}
+}
+
+func TestMaxPatterns(t *testing.T) {
+	ps, err := Process("10.0.0.0/23", MaxPatterns(2))
+	if err != nil || len(ps) != 2 {
+		t.Error(ps, err)
+	}
+	if ps, err = Process("10.0.0.0/22", MaxPatterns(2)); !errors.Is(err, ErrTooManyPatterns) || ps != nil {
+		t.Error(ps, err)
+	}
+	if ps, err = Process("::/8", MaxPatterns(100), Sort(OrderLexical)); !errors.Is(err, ErrTooManyPatterns) {
+		t.Error(len(ps), err)
+	}
+	if ps, err = Process("10.0.0.0/22", MaxPatterns(3), Limit(2)); err != nil || len(ps) != 2 {
+		t.Error(ps, err)
+	}
+	if ps, err = ProcessMany([]string{"10.0.0.0/24", "10.0.2.0/24"}, MaxPatterns(1)); !errors.Is(err, ErrTooManyPatterns) {
+		t.Error(ps, err)
+	}
+	pts, err := ProcessPatterns("10.0.0.0/22", MaxPatterns(3))
+	if !errors.Is(err, ErrTooManyPatterns) || pts != nil {
+		t.Error(pts, err)
+	}
+}
+
+func TestMapIPv4(t *testing.T) {
+	tests := []struct {
+		s        string
+		m        IPv4Mapping
+		expected []string
+	}{
+		{"::ffff:10.0.0.0/104", IPv4Unmapped, []string{"10.*"}},
+		{"::ffff:10.0.0.0/104", IPv4Keep, []string{"::ffff:10.*"}},
+		{"10.0.0.0/8", IPv4Mapped, []string{"::ffff:10.*"}},
+		{"10.0.0.0/8", IPv4Unmapped, []string{"10.*"}},
+		{"2001:db8::/32", IPv4Unmapped, []string{"2001:db8:*"}},
+		{"::fffe:ffff:ffff-::ffff:0.255.255.255", IPv4Unmapped, []string{"0.*", "::fffe:ffff:ffff"}},
+	}
+	for _, mt := range tests {
+		ps, err := Process(mt.s, MapIPv4(mt.m))
+		if err != nil || !validate(ps, mt.expected) {
+			t.Error(mt.s, mt.m, ps, err)
+		}
+	}
+
+	pts, err := ProcessPatterns("::ffff:10.0.0.0/104", MapIPv4(IPv4Unmapped))
+	if err != nil || len(pts) != 1 || pts[0].Family != 4 {
+		t.Error(pts, err)
+	}
+	ps, err := Process("10.0.0.0/8", MapIPv4(IPv4Mapped), ExcludeReserved())
+	if err != nil || len(ps) != 0 {
+		t.Error(ps, err)
+	}
+	ps, err = Process("11.0.0.0/8", MapIPv4(IPv4Mapped), ExcludeReserved())
+	if err != nil || !validate(ps, []string{"::ffff:11.*"}) {
+		t.Error(ps, err)
+	}
+}
@@ -0,0 +1,97 @@
+// Copyright 2023-now by lifenjoiner. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE file.
+
+package iprefix
+
+import (
+	"fmt"
+	"net/netip"
+	"strconv"
+)
+
+// Cursor is where a paginated expansion continues, the zero value for the
+// first page. Its text is the token to request the next page with.
+type Cursor struct {
+	offset int
+	done   bool
+}
+
+const cursorEnd = "end"
+
+// Done reports whether there is no more page.
+func (c Cursor) Done() bool {
+	return c.done
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (c Cursor) MarshalText() ([]byte, error) {
+	if c.done {
+		return []byte(cursorEnd), nil
+	}
+	return strconv.AppendInt(nil, int64(c.offset), 36), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (c *Cursor) UnmarshalText(b []byte) error {
+	if string(b) == cursorEnd {
+		*c = Cursor{done: true}
+		return nil
+	}
+	n, err := strconv.ParseInt(string(b), 36, 0)
+	if err != nil || n < 0 {
+		return fmt.Errorf("invalid cursor: %q", b)
+	}
+	*c = Cursor{offset: int(n)}
+	return nil
+}
+
+// ProcessCIDRPage generates at most `pageSize` string IP prefix patterns from
+// CIDR, from `cursor` on, and the cursor of the next page. The pages are
+// found from the offset directly, without generating the skipped patterns.
+func ProcessCIDRPage(s string, cursor Cursor, pageSize int) (ps []string, next Cursor, err error) {
+	if pageSize <= 0 {
+		err = fmt.Errorf("invalid page size: %d", pageSize)
+		return
+	}
+	p, err := parsePrefix(s)
+	if err != nil {
+		return
+	}
+	total := PatternCount(p)
+	if cursor.done || cursor.offset >= total {
+		return nil, Cursor{done: true}, nil
+	}
+	from, skip := p.Masked().Addr(), cursor.offset
+	if !p.IsSingleIP() {
+		// the value of the varying block, and the patterns of it to skip
+		sp := spanOf(p)
+		v := sp.sv
+		if v == 0 && skip >= sp.zero {
+			v, skip = 1, skip-sp.zero
+		}
+		if v != 0 {
+			v += uint16(skip / sp.nonzero)
+			skip %= sp.nonzero
+		}
+		ip := from.AsSlice()
+		if sp.width == 8 {
+			ip[sp.offset/8] = byte(v)
+		} else {
+			setbeUint16(ip, sp.offset/16, v)
+		}
+		from, _ = netip.AddrFromSlice(ip)
+	}
+	ps = make([]string, 0, min(pageSize, total-cursor.offset))
+	processRange(from, lastAddr(p), IPv6All, func(pt string) bool {
+		if skip > 0 {
+			skip--
+			return true
+		}
+		ps = append(ps, pt)
+		return len(ps) < pageSize
+	})
+	next.offset = cursor.offset + len(ps)
+	next.done = next.offset >= total
+	return
+}
@@ -0,0 +1,62 @@
+// Copyright 2023-now by lifenjoiner. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE file.
+
+package iprefix
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestProcessCIDRPage(t *testing.T) {
+	tests := []struct {
+		s    string
+		size int
+	}{
+		{"10.0.0.1/32", 3},
+		{"10.0.0.0/22", 3},
+		{"10.0.0.0/7", 100},
+		{"::ffff:10.0.0.0/102", 2},
+		{"::/112", 1},
+		{"0:0:0:1::/64", 1},
+		{"1111::/24", 7},
+		{"1111:0:0:4444::/72", 5},
+		{"2001:db8::/116", 1000},
+	}
+	for _, mt := range tests {
+		expected, _ := ProcessCIDR(mt.s)
+		var ps []string
+		var cursor Cursor
+		for !cursor.Done() {
+			// the token round trip
+			token, _ := cursor.MarshalText()
+			if err := cursor.UnmarshalText(token); err != nil {
+				t.Fatal(mt.s, err)
+			}
+			page, next, err := ProcessCIDRPage(mt.s, cursor, mt.size)
+			if err != nil || len(page) > mt.size || len(page) == 0 {
+				t.Fatal(mt.s, cursor, len(page), err)
+			}
+			ps = append(ps, page...)
+			cursor = next
+		}
+		if !slices.Equal(ps, expected) {
+			t.Error(mt.s, ps)
+		}
+	}
+
+	var c Cursor
+	if c.UnmarshalText([]byte("x!")) == nil || c.UnmarshalText([]byte("-1")) == nil {
+		t.Error("invalid cursor")
+	}
+	if _, _, err := ProcessCIDRPage("10.0.0.0/8", Cursor{}, 0); err == nil {
+		t.Error("page size")
+	}
+	if _, _, err := ProcessCIDRPage("10.0.0.0/33", Cursor{}, 1); err == nil {
+		t.Error("10.0.0.0/33")
+	}
+	if ps, next, err := ProcessCIDRPage("10.0.0.0/8", Cursor{offset: 9}, 1); ps != nil || !next.Done() || err != nil {
+		t.Error(ps, next, err)
+	}
+}
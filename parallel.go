@@ -0,0 +1,87 @@
+// Copyright 2023-now by lifenjoiner. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE file.
+
+package iprefix
+
+import (
+	"net/netip"
+	"runtime"
+	"sync"
+)
+
+// parallelMin is the least count of patterns worth sharding.
+const parallelMin = 4096
+
+// shards splits `p` into 2^n prefixes varying the same block, as many as
+// `n` allows without crossing the block.
+func shards(p netip.Prefix, n int) (ps []netip.Prefix) {
+	bs := 16
+	if p.Addr().Is4() {
+		bs = 8
+	}
+	m := p.Bits()
+	if end := (m + bs - 1) / bs * bs; m+n > end {
+		n = end - m
+	}
+	addr := p.Masked().Addr()
+	for i := 0; i < 1<<n; i++ {
+		sp := netip.PrefixFrom(addr, m+n)
+		ps = append(ps, sp)
+		addr = lastAddr(sp).Next()
+	}
+	return
+}
+
+// ProcessPrefixParallel is ProcessPrefix sharding the block values of wide
+// prefixes across `workers` goroutines, GOMAXPROCS if not positive. The
+// patterns are in the same order.
+func ProcessPrefixParallel(p netip.Prefix, workers int) []string {
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	if workers == 1 || p.IsSingleIP() || PatternCount(p) < parallelMin {
+		return ProcessPrefix(p)
+	}
+	// a few shards a worker to balance the uneven ones
+	n := 0
+	for 1<<n < workers*4 {
+		n++
+	}
+	sps := shards(p, n)
+	results := make([][]string, len(sps))
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for range min(workers, len(sps)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				results[i] = ProcessPrefix(sps[i])
+			}
+		}()
+	}
+	for i := range sps {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+	size := 0
+	for _, r := range results {
+		size += len(r)
+	}
+	ps := make([]string, 0, size)
+	for _, r := range results {
+		ps = append(ps, r...)
+	}
+	return ps
+}
+
+// ProcessCIDRParallel is ProcessCIDR by ProcessPrefixParallel.
+func ProcessCIDRParallel(s string, workers int) (ps []string, err error) {
+	p, err := parsePrefix(s)
+	if err != nil {
+		return
+	}
+	return ProcessPrefixParallel(p, workers), nil
+}
@@ -0,0 +1,46 @@
+// Copyright 2023-now by lifenjoiner. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE file.
+
+package iprefix
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestProcessCIDRParallel(t *testing.T) {
+	tests := []struct {
+		s       string
+		workers int
+	}{
+		{"10.0.0.0/8", 0},
+		{"2001:db8::/100", 0},
+		{"2001:db8::/100", 3},
+		{"2001:db8::/97", 8},
+		{"::/80", 4},
+		{"::ffff:10.0.0.0/104", 4},
+	}
+	for _, mt := range tests {
+		expected, _ := ProcessCIDR(mt.s)
+		ps, err := ProcessCIDRParallel(mt.s, mt.workers)
+		if err != nil || !slices.Equal(ps, expected) {
+			t.Error(mt.s, mt.workers, len(ps), len(expected), err)
+		}
+	}
+	if _, err := ProcessCIDRParallel("10.0.0.0/33", 0); err == nil {
+		t.Error("10.0.0.0/33")
+	}
+}
+
+func BenchmarkProcessCIDRSequential(b *testing.B) {
+	for range b.N {
+		ProcessCIDR("2001:db8::/100")
+	}
+}
+
+func BenchmarkProcessCIDRParallel(b *testing.B) {
+	for range b.N {
+		ProcessCIDRParallel("2001:db8::/100", 0)
+	}
+}
@@ -0,0 +1,277 @@
+// Copyright 2023-now by lifenjoiner. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE file.
+
+package iprefix
+
+import (
+	"fmt"
+	"math/big"
+	"math/bits"
+	"net/netip"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Pattern is a string IP prefix pattern with the prefix it stands for.
+type Pattern struct {
+	Text   string
+	Prefix netip.Prefix
+	// Family is 4 or 6.
+	Family int
+	// Wildcard reports whether Text ends with the wildcard, or else it is
+	// an IP.
+	Wildcard bool
+}
+
+// Count returns the count of IPs of the prefix that the pattern stands for.
+func (pt Pattern) Count() *big.Int {
+	return PrefixCount(pt.Prefix)
+}
+
+func parseHextets(s string) (hs []uint16, err error) {
+	if len(s) == 0 {
+		return
+	}
+	for _, x := range strings.Split(s, ":") {
+		if len(x) == 0 || len(x) > 4 {
+			return nil, fmt.Errorf("%w: hextet %q", ErrInvalidPattern, x)
+		}
+		v, err := strconv.ParseUint(x, 16, 16)
+		if err != nil {
+			return nil, fmt.Errorf("%w: hextet %q", ErrInvalidPattern, x)
+		}
+		hs = append(hs, uint16(v))
+	}
+	return
+}
+
+// ParsePattern parses a string IP prefix pattern back to the prefix it stands
+// for, the broadest one generated as the pattern.
+//
+// The IPv6 texts compress the longest run of zero blocks, so the text doesn't
+// tell how many zero blocks the `::` is. Before other blocks, it is read as
+// two, the least run compressed, like `1111::4444:*` as `1111:0:0:4444::/64`.
+// Before the wildcard, it is read as one, like `1111::*` as `1111::/32`. Such
+// a pattern is generated from the prefixes of more zero blocks as well, and by
+// its text it may match only a part of the IPs of its prefix. NewPatternSet
+// takes the patterns exactly.
+func ParsePattern(s string) (p netip.Prefix, err error) {
+	if !strings.HasSuffix(s, "*") {
+		var addr netip.Addr
+		if addr, err = parseAddr(s); err != nil {
+			return
+		}
+		return netip.PrefixFrom(addr, addr.BitLen()), nil
+	}
+	head := s[:len(s)-1]
+	if strings.HasSuffix(head, ".") {
+		// IPv4 or 4in6
+		i := strings.LastIndexByte(head, ':')
+		v4 := head[i+1 : len(head)-1]
+		n := strings.Count(v4, ".") + 1
+		if len(v4) == 0 || n > 3 {
+			err = fmt.Errorf("%w: %q", ErrInvalidPattern, s)
+			return
+		}
+		v4 += strings.Repeat(".0", 4-n)
+		var addr netip.Addr
+		if addr, err = netip.ParseAddr(head[:i+1] + v4); err != nil {
+			err = fmt.Errorf("%w: %q", ErrInvalidPattern, s)
+			return
+		}
+		if i < 0 {
+			return netip.PrefixFrom(addr, 8*n), nil
+		}
+		if !addr.Is4In6() {
+			err = fmt.Errorf("%w: %q", ErrInvalidPattern, s)
+			return
+		}
+		return netip.PrefixFrom(addr, 96+8*n), nil
+	}
+	if !strings.HasSuffix(head, ":") {
+		err = fmt.Errorf("%w: %q", ErrInvalidPattern, s)
+		return
+	}
+	var hs []uint16
+	if strings.HasSuffix(head, "::") {
+		if hs, err = parseHextets(head[:len(head)-2]); err != nil {
+			return
+		}
+		hs = append(hs, 0)
+	} else if l, r, ok := strings.Cut(head[:len(head)-1], "::"); ok {
+		var hr []uint16
+		if hs, err = parseHextets(l); err != nil {
+			return
+		}
+		if hr, err = parseHextets(r); err != nil {
+			return
+		}
+		hs = append(append(hs, 0, 0), hr...)
+	} else if hs, err = parseHextets(head[:len(head)-1]); err != nil {
+		return
+	}
+	if len(hs) == 0 || len(hs) > 7 || strings.Count(head, "::") > 1 {
+		err = fmt.Errorf("%w: %q", ErrInvalidPattern, s)
+		return
+	}
+	var ip [16]byte
+	for i, h := range hs {
+		setbeUint16(ip[:], i, h)
+	}
+	return netip.PrefixFrom(netip.AddrFrom16(ip), 16*len(hs)), nil
+}
+
+// ParseWildcardMask parses a Cisco ACL address and inverse mask pair, like
+// `10.0.0.0 0.0.1.255`. Only the contiguous masks stand for prefixes.
+func ParseWildcardMask(s string) (p netip.Prefix, err error) {
+	return parseMaskPair(s, "wildcard mask", true)
+}
+
+// ParseNetmask parses an address and netmask pair, like
+// `10.0.0.0 255.255.254.0`. Only the contiguous masks stand for prefixes.
+func ParseNetmask(s string) (p netip.Prefix, err error) {
+	return parseMaskPair(s, "netmask", false)
+}
+
+// parseMaskPair parses an IPv4 address and mask pair, the mask inverse if
+// `inverse`.
+func parseMaskPair(s, kind string, inverse bool) (p netip.Prefix, err error) {
+	f := strings.Fields(s)
+	if len(f) != 2 {
+		err = fmt.Errorf("%w: %s %q", ErrInvalidCIDR, kind, s)
+		return
+	}
+	var addr, mask netip.Addr
+	if addr, err = parseAddr(f[0]); err != nil {
+		return
+	}
+	if mask, err = parseAddr(f[1]); err != nil {
+		return
+	}
+	if !addr.Is4() || !mask.Is4() {
+		err = fmt.Errorf("%w: %s %q", ErrInvalidCIDR, kind, s)
+		return
+	}
+	m := mask.As4()
+	w := uint32(m[0])<<24 | uint32(m[1])<<16 | uint32(m[2])<<8 | uint32(m[3])
+	if !inverse {
+		w = ^w
+	}
+	if w&(w+1) != 0 {
+		err = fmt.Errorf("%w: %s %q", ErrInvalidCIDR, kind, s)
+		return
+	}
+	return netip.PrefixFrom(addr, 32-bits.OnesCount32(w)).Masked(), nil
+}
+
+// Canonicalize removes the patterns subsumed by the broader ones, including
+// the duplicate IPv6 spellings. The left ones keep their order, and the
+// invalid ones are kept untouched.
+func Canonicalize(ps []string) (cs []string) {
+	type entry struct {
+		addrRange
+		i int
+	}
+	var es []entry
+	for i, s := range ps {
+		if r, err := parseEntry(s); err == nil {
+			es = append(es, entry{r, i})
+		}
+	}
+	sort.Slice(es, func(i, j int) bool {
+		if c := es[i].from.Compare(es[j].from); c != 0 {
+			return c < 0
+		}
+		if c := es[i].to.Compare(es[j].to); c != 0 {
+			return c > 0
+		}
+		return es[i].i < es[j].i
+	})
+	drop := make([]bool, len(ps))
+	var maxTo netip.Addr
+	for _, e := range es {
+		if maxTo.BitLen() == e.to.BitLen() && !maxTo.Less(e.to) {
+			drop[e.i] = true
+			continue
+		}
+		maxTo = e.to
+	}
+	for i, s := range ps {
+		if !drop[i] {
+			cs = append(cs, s)
+		}
+	}
+	return
+}
+
+// Reexpand regenerates a pattern, a CIDR, an IP range `IP1-IP2` or an IP at
+// the depth of prefix length `bits`, like `10.1.*` at 24 to 256 `10.1.x.*`,
+// tuned by `opts`. The prefixes already deeper than `bits` are kept as they
+// are.
+func Reexpand(s string, bits int, opts ...Option) (ps []string, err error) {
+	r, err := parseEntry(s)
+	if err != nil {
+		return
+	}
+	if bits < 0 || bits > r.from.BitLen() {
+		err = fmt.Errorf("invalid depth: %d", bits)
+		return
+	}
+	o := newOptions(opts)
+	return o.output(func(yield func(string) bool) bool {
+		for _, r := range o.ranges([]addrRange{r}) {
+			for _, p := range r.prefixes() {
+				if p.Bits() >= bits {
+					if !o.processPrefix(p, yield) {
+						return false
+					}
+					continue
+				}
+				end := lastAddr(p)
+				for addr := p.Addr(); ; {
+					sub := netip.PrefixFrom(addr, bits)
+					if !o.processPrefix(sub, yield) {
+						return false
+					}
+					if addr = lastAddr(sub); addr == end {
+						break
+					}
+					addr = addr.Next()
+				}
+			}
+		}
+		return true
+	})
+}
+
+// ComparePatterns compares patterns by the address of the prefixes they stand
+// for as ParsePattern reads them, IPv4 before IPv6, then the broader before
+// the narrower, then as strings. Invalid patterns are after the valid ones.
+func ComparePatterns(a, b string) int {
+	pa, erra := ParsePattern(a)
+	pb, errb := ParsePattern(b)
+	switch {
+	case erra != nil && errb != nil:
+		return strings.Compare(a, b)
+	case erra != nil:
+		return 1
+	case errb != nil:
+		return -1
+	}
+	if c := pa.Addr().Compare(pb.Addr()); c != 0 {
+		return c
+	}
+	if c := pa.Bits() - pb.Bits(); c != 0 {
+		return c
+	}
+	return strings.Compare(a, b)
+}
+
+// SortPatterns sorts patterns by ComparePatterns, like `10.2.*` before
+// `10.10.*`.
+func SortPatterns(ps []string) {
+	slices.SortStableFunc(ps, ComparePatterns)
+}
@@ -0,0 +1,196 @@
+// Copyright 2023-now by lifenjoiner. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE file.
+
+package iprefix
+
+import (
+	"net/netip"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestParsePattern(t *testing.T) {
+	tests := []struct {
+		s        string
+		expected string
+	}{
+		{"127.*", "127.0.0.0/8"},
+		{"10.1.2.*", "10.1.2.0/24"},
+		{"10.1.2.3", "10.1.2.3/32"},
+		{"::ffff:10.1.*", "::ffff:10.1.0.0/112"},
+		{"::ffff:172.16.0.1", "::ffff:172.16.0.1/128"},
+		{"1111::4444:*", "1111:0:0:4444::/64"},
+		{"0:0:3333::*", "0:0:3333::/64"},
+		{"::3333:0:*", "0:0:3333::/64"},
+		{"1111::*", "1111::/32"},
+		{"::*", "::/16"},
+		{"::ffff:*", "0:0:ffff::/48"},
+		{"2001:20:*", "2001:20::/32"},
+		{"1111:0:0:4444:5555::", "1111:0:0:4444:5555::/128"},
+	}
+	for _, mt := range tests {
+		p, err := ParsePattern(mt.s)
+		if err != nil || p.String() != mt.expected {
+			t.Error(mt.s, p, err)
+		}
+	}
+
+	for _, s := range []string{"*", "10.*.1", "1.2.3.4.*", "256.*", "1::2::*", "1:2:3:4:5:6:7:8:*", "12345:*", "2001:20*", "::ffff.*"} {
+		if _, err := ParsePattern(s); err == nil {
+			t.Error(s)
+		}
+	}
+}
+
+func TestParsePatternRoundTrip(t *testing.T) {
+	prepareExpected()
+	for _, mt := range test {
+		p, err := netip.ParsePrefix(mt.CIDR)
+		if err != nil {
+			continue
+		}
+		p = p.Masked()
+		for _, ps := range ProcessPrefix(p) {
+			// generated as the pattern
+			pp, err := ParsePattern(ps)
+			if err != nil || !slices.Contains(ProcessPrefix(pp), ps) {
+				t.Error(mt.CIDR, ps, pp, err)
+			}
+		}
+	}
+}
+
+func TestCanonicalize(t *testing.T) {
+	tests := []struct {
+		ps       []string
+		expected []string
+	}{
+		{[]string{"10.0.1.*", "10.0.*", "10.0.1.2", "10.1.*", "10.0.*"}, []string{"10.0.*", "10.1.*"}},
+		{[]string{"1111:0:*", "1111::*"}, []string{"1111:0:*"}},
+		{[]string{"::3333:0:*", "0:0:3333:0:*", "0:0:3333::*"}, []string{"::3333:0:*"}},
+		{[]string{"x", "10.*", "10.0.0.0/16", "::ffff:10.*"}, []string{"x", "10.*", "::ffff:10.*"}},
+	}
+	for _, mt := range tests {
+		r := Canonicalize(mt.ps)
+		if strings.Join(r, "\n") != strings.Join(mt.expected, "\n") {
+			t.Error(mt.ps, r)
+		}
+	}
+}
+
+func TestParseWildcardMask(t *testing.T) {
+	tests := []struct {
+		s        string
+		expected string
+	}{
+		{"10.0.0.0 0.0.1.255", "10.0.0.0/23"},
+		{"10.0.1.7  0.0.0.0", "10.0.1.7/32"},
+		{"10.0.1.7 0.0.0.255", "10.0.1.0/24"},
+		{"0.0.0.0 255.255.255.255", "0.0.0.0/0"},
+		{"10.0.0.0 0.0.1.254", ""},
+		{"10.0.0.0 0.255.0.255", ""},
+		{"10.0.0.0", ""},
+		{"::1 0.0.0.0", ""},
+		{"10.0.0.0 ::ff", ""},
+		{"10.0.0.0 ::ffff:0.0.0.255", ""},
+	}
+	for _, mt := range tests {
+		p, err := ParseWildcardMask(mt.s)
+		if mt.expected == "" {
+			if err == nil {
+				t.Error(mt.s, p)
+			}
+		} else if err != nil || p.String() != mt.expected {
+			t.Error(mt.s, p, err)
+		}
+	}
+}
+
+func TestParseNetmask(t *testing.T) {
+	tests := []struct {
+		s        string
+		expected string
+	}{
+		{"10.0.0.0 255.255.254.0", "10.0.0.0/23"},
+		{"10.0.1.7  255.255.255.255", "10.0.1.7/32"},
+		{"10.0.1.7 255.255.255.0", "10.0.1.0/24"},
+		{"0.0.0.0 0.0.0.0", "0.0.0.0/0"},
+		{"10.0.0.0 255.255.254.1", ""},
+		{"10.0.0.0 255.0.255.0", ""},
+		{"10.0.0.0 0.0.0.255", ""},
+		{"10.0.0.0", ""},
+		{"::1 255.0.0.0", ""},
+		{"10.0.0.0 1::255.255.255.0", ""},
+		{"10.0.0.0 ffff:ffff::", ""},
+	}
+	for _, mt := range tests {
+		p, err := ParseNetmask(mt.s)
+		if mt.expected == "" {
+			if err == nil {
+				t.Error(mt.s, p)
+			}
+		} else if err != nil || p.String() != mt.expected {
+			t.Error(mt.s, p, err)
+		}
+	}
+}
+
+func TestReexpand(t *testing.T) {
+	ps, err := Reexpand("10.1.*", 24)
+	if err != nil || len(ps) != 256 || ps[0] != "10.1.0.*" || ps[255] != "10.1.255.*" {
+		t.Error(len(ps), err)
+	}
+	tests := []struct {
+		s        string
+		bits     int
+		expected []string
+	}{
+		{"10.1.*", 16, []string{"10.1.*"}},
+		{"10.1.*", 8, []string{"10.1.*"}},
+		{"10.1.2.*", 16, []string{"10.1.2.*"}},
+		{"10.1.0.0/23", 32, nil},
+		{"2001:db8:*", 48, nil},
+		{"10.1.0.0/31", 32, []string{"10.1.0.0", "10.1.0.1"}},
+		{"10.0.0.0-10.0.1.255", 23, []string{"10.0.0.*", "10.0.1.*"}},
+		{"10.*", 33, []string{}},
+		{"x", 24, []string{}},
+	}
+	for _, mt := range tests {
+		ps, err := Reexpand(mt.s, mt.bits)
+		switch {
+		case mt.expected == nil:
+			if err != nil {
+				t.Error(mt.s, err)
+			}
+		case len(mt.expected) == 0:
+			if err == nil {
+				t.Error(mt.s, ps)
+			}
+		case err != nil || !validate(ps, mt.expected):
+			t.Error(mt.s, ps, err)
+		}
+	}
+	if ps, _ = Reexpand("10.1.0.0/23", 32); len(ps) != 512 {
+		t.Error(len(ps))
+	}
+	if ps, _ = Reexpand("2001:db8:*", 48); len(ps) != 65537 {
+		t.Error(len(ps))
+	}
+	if ps, _ = Reexpand("::*", 64, Limit(3)); strings.Join(ps, ",") != "::*,::1:*,0:0:0:1::" {
+		t.Error(ps)
+	}
+}
+
+func TestSortPatterns(t *testing.T) {
+	ps := []string{"x", "2001:db8:*", "10.10.*", "::ffff:10.*", "10.2.*", "10.2.0.1", "10.*", "2001:db8:0:*", "10.2.0.*", "a"}
+	SortPatterns(ps)
+	expected := "10.*,10.2.*,10.2.0.*,10.2.0.1,10.10.*,::ffff:10.*,2001:db8:*,2001:db8:0:*,a,x"
+	if strings.Join(ps, ",") != expected {
+		t.Error(ps)
+	}
+	if ComparePatterns("10.2.*", "10.10.*") >= 0 || ComparePatterns("10.*", "10.*") != 0 || ComparePatterns("x", "10.*") <= 0 {
+		t.Error("ComparePatterns")
+	}
+}
@@ -0,0 +1,46 @@
+// Copyright 2023-now by lifenjoiner. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE file.
+
+package iprefix
+
+import (
+	"net/netip"
+	"sync"
+)
+
+// bufPool pools the formatting buffers of the generators, so batches of
+// entries don't allocate them for each one.
+var bufPool = sync.Pool{
+	New: func() any {
+		b := make([]byte, 0, len("ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff"))
+		return &b
+	},
+}
+
+// prefixPool pools the scratch prefixes of the decomposed ranges.
+var prefixPool = sync.Pool{
+	New: func() any {
+		ps := make([]netip.Prefix, 0, 32)
+		return &ps
+	},
+}
+
+func getBuf() *[]byte {
+	return bufPool.Get().(*[]byte)
+}
+
+// putBuf returns a buffer, keeping its grown capacity.
+func putBuf(b *[]byte, buf []byte) {
+	*b = buf[:0]
+	bufPool.Put(b)
+}
+
+func getPrefixes() *[]netip.Prefix {
+	return prefixPool.Get().(*[]netip.Prefix)
+}
+
+func putPrefixes(p *[]netip.Prefix, ps []netip.Prefix) {
+	*p = ps[:0]
+	prefixPool.Put(p)
+}
@@ -0,0 +1,275 @@
+// Copyright 2023-now by lifenjoiner. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE file.
+
+package iprefix
+
+import (
+	"fmt"
+	"math/big"
+	"net/netip"
+	"sort"
+)
+
+// addrRange is an IP range of the same type, `from` <= `to`.
+type addrRange struct {
+	from, to netip.Addr
+}
+
+func lastAddr(p netip.Prefix) netip.Addr {
+	addr := p.Masked().Addr()
+	ip := addr.AsSlice()
+	for i := p.Bits(); i < len(ip)*8; i++ {
+		ip[i/8] |= 0x80 >> (i % 8)
+	}
+	if addr.Is4() {
+		return netip.AddrFrom4([4]byte(ip))
+	}
+	return netip.AddrFrom16([16]byte(ip))
+}
+
+func prefixRange(p netip.Prefix) addrRange {
+	return addrRange{p.Masked().Addr(), lastAddr(p)}
+}
+
+// mergeRanges sorts the ranges and merges the overlapping or adjacent ones.
+func mergeRanges(rs []addrRange) []addrRange {
+	if len(rs) == 0 {
+		return rs
+	}
+	sort.Slice(rs, func(i, j int) bool {
+		return rs[i].from.Less(rs[j].from)
+	})
+	m := rs[:1]
+	for _, r := range rs[1:] {
+		last := &m[len(m)-1]
+		next := last.to.Next()
+		if r.from.BitLen() == last.to.BitLen() && (!next.IsValid() || r.from.Compare(next) <= 0) {
+			if last.to.Less(r.to) {
+				last.to = r.to
+			}
+			continue
+		}
+		m = append(m, r)
+	}
+	return m
+}
+
+// searchRanges finds the index of the range containing `addr` in merged ranges,
+// or -1.
+func searchRanges(rs []addrRange, addr netip.Addr) int {
+	i := sort.Search(len(rs), func(i int) bool {
+		return addr.Compare(rs[i].to) <= 0
+	})
+	if i < len(rs) && rs[i].from.Compare(addr) <= 0 {
+		return i
+	}
+	return -1
+}
+
+// prefixes decomposes the range into the least prefixes.
+func (r addrRange) prefixes() []netip.Prefix {
+	return r.appendPrefixes(nil)
+}
+
+// appendPrefixes appends the least prefixes of the range to `ps`.
+func (r addrRange) appendPrefixes(ps []netip.Prefix) []netip.Prefix {
+	from := r.from
+	for {
+		bits := from.BitLen()
+		for bits > 0 {
+			p := netip.PrefixFrom(from, bits-1)
+			if p.Masked().Addr() != from || lastAddr(p).Compare(r.to) > 0 {
+				break
+			}
+			bits--
+		}
+		p := netip.PrefixFrom(from, bits)
+		ps = append(ps, p)
+		last := lastAddr(p)
+		if last.Compare(r.to) >= 0 {
+			break
+		}
+		from = last.Next()
+	}
+	return ps
+}
+
+func rangesPrefixes(rs []addrRange) (ps []netip.Prefix) {
+	for _, r := range rs {
+		ps = append(ps, r.prefixes()...)
+	}
+	return
+}
+
+// RangeToPrefixes decomposes IP range into the least prefixes.
+// `start` is start IP. `end` is end IP. It returns nil for an invalid range.
+func RangeToPrefixes(start, end netip.Addr) []netip.Prefix {
+	if checkRange(start, end) != nil {
+		return nil
+	}
+	return addrRange{start, end}.prefixes()
+}
+
+// PrefixToRange returns the first and the last IP of the prefix, or zero
+// values for an invalid prefix, such as the zero value.
+func PrefixToRange(p netip.Prefix) (netip.Addr, netip.Addr) {
+	if !p.IsValid() {
+		return netip.Addr{}, netip.Addr{}
+	}
+	r := prefixRange(p)
+	return r.from, r.to
+}
+
+// subtractRanges removes merged ranges `b` from merged ranges `a`.
+func subtractRanges(a, b []addrRange) (rs []addrRange) {
+	j := 0
+	for _, r := range a {
+		for j < len(b) && b[j].to.Less(r.from) {
+			j++
+		}
+		from := r.from
+		covered := false
+		for k := j; k < len(b) && b[k].from.Compare(r.to) <= 0; k++ {
+			if from.Less(b[k].from) {
+				rs = append(rs, addrRange{from, b[k].from.Prev()})
+			}
+			if !b[k].to.Less(r.to) {
+				covered = true
+				break
+			}
+			from = b[k].to.Next()
+		}
+		if !covered {
+			rs = append(rs, addrRange{from, r.to})
+		}
+	}
+	return
+}
+
+func rangesPatterns(rs []addrRange) []string {
+	return prefixesPatterns(rangesPrefixes(rs))
+}
+
+// intersectRanges returns the overlapping ranges of merged ranges `a` and `b`.
+func intersectRanges(a, b []addrRange) (rs []addrRange) {
+	for i, j := 0, 0; i < len(a) && j < len(b); {
+		from, to := a[i].from, a[i].to
+		if from.Less(b[j].from) {
+			from = b[j].from
+		}
+		if b[j].to.Less(to) {
+			to = b[j].to
+		}
+		if !to.Less(from) {
+			rs = append(rs, addrRange{from, to})
+		}
+		if a[i].to.Less(b[j].to) {
+			i++
+		} else {
+			j++
+		}
+	}
+	return
+}
+
+// count returns the count of IPs in the range.
+func (r addrRange) count() *big.Int {
+	n := new(big.Int).SetBytes(r.to.AsSlice())
+	n.Sub(n, new(big.Int).SetBytes(r.from.AsSlice()))
+	return n.Add(n, big.NewInt(1))
+}
+
+// PrefixCount returns the count of IPs in the prefix, 0 for an invalid one.
+func PrefixCount(p netip.Prefix) *big.Int {
+	if !p.IsValid() {
+		return new(big.Int)
+	}
+	return new(big.Int).Lsh(big.NewInt(1), uint(p.Addr().BitLen()-p.Bits()))
+}
+
+// Expand lists the IPs of a pattern, a CIDR, an IP range `IP1-IP2` or an IP.
+// It fails if there are more than `limit` IPs.
+func Expand(s string, limit int) (addrs []netip.Addr, err error) {
+	r, err := parseEntry(s)
+	if err != nil {
+		return
+	}
+	if n := r.count(); n.Cmp(big.NewInt(int64(limit))) > 0 {
+		err = fmt.Errorf("%v IPs exceed the limit %d", n, limit)
+		return
+	}
+	for addr := r.from; ; addr = addr.Next() {
+		addrs = append(addrs, addr)
+		if addr == r.to {
+			break
+		}
+	}
+	return
+}
+
+// Range is an IP range, `Start` <= `End`.
+type Range struct {
+	Start, End netip.Addr
+}
+
+// Patterns generates string IP prefix pattern of the range.
+func (r Range) Patterns() []string {
+	ps, _ := ProcessAddrRange(r.Start, r.End)
+	return ps
+}
+
+// SplitRange splits IP range into `n` contiguous sub-ranges of about the same
+// size, or single IPs if there are less than `n` IPs.
+// `start` is start IP. `end` is end IP.
+func SplitRange(start, end netip.Addr, n int) (rs []Range, err error) {
+	if err = checkRange(start, end); err != nil {
+		return
+	}
+	if n <= 0 {
+		err = fmt.Errorf("invalid count: %d", n)
+		return
+	}
+	c := addrRange{start, end}.count()
+	size, rem := new(big.Int).QuoRem(c, big.NewInt(int64(n)), new(big.Int))
+	one := big.NewInt(1)
+	from := start
+	for i := 0; i < n; i++ {
+		k := new(big.Int).Set(size)
+		if int64(i) < rem.Int64() {
+			k.Add(k, one)
+		}
+		if k.Sign() == 0 {
+			break
+		}
+		to := addrAdd(from, k.Sub(k, one))
+		rs = append(rs, Range{from, to})
+		from = to.Next()
+	}
+	return
+}
+
+// mappedRange is the 4in6 address space.
+var mappedRange = prefixRange(netip.MustParsePrefix("::ffff:0:0/96"))
+
+// mapRanges converts the IPv4 ranges to 4in6, or the 4in6 parts of IPv6 ones to
+// IPv4, as `m` tells.
+func mapRanges(rs []addrRange, m IPv4Mapping) (ms []addrRange) {
+	for _, r := range rs {
+		switch {
+		case m == IPv4Mapped && r.from.Is4():
+			ms = append(ms, addrRange{netip.AddrFrom16(r.from.As16()), netip.AddrFrom16(r.to.As16())})
+		case m == IPv4Unmapped && r.from.Is6():
+			x := intersectRanges([]addrRange{r}, []addrRange{mappedRange})
+			if len(x) == 0 {
+				ms = append(ms, r)
+				continue
+			}
+			ms = append(ms, subtractRanges([]addrRange{r}, x)...)
+			ms = append(ms, addrRange{x[0].from.Unmap(), x[0].to.Unmap()})
+		default:
+			ms = append(ms, r)
+		}
+	}
+	return mergeRanges(ms)
+}
@@ -0,0 +1,132 @@
+// Copyright 2023-now by lifenjoiner. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE file.
+
+package iprefix
+
+import (
+	"fmt"
+	"net/netip"
+	"strings"
+	"testing"
+)
+
+func TestRangeToPrefixes(t *testing.T) {
+	tests := []struct {
+		Range    [2]string
+		expected string
+	}{
+		{[2]string{"10.0.0.254", "10.0.2.1"}, "[10.0.0.254/31 10.0.1.0/24 10.0.2.0/31]"},
+		{[2]string{"0.0.0.0", "255.255.255.255"}, "[0.0.0.0/0]"},
+		{[2]string{"10.0.0.1", "10.0.0.1"}, "[10.0.0.1/32]"},
+		{[2]string{"::", "::2"}, "[::/127 ::2/128]"},
+		{[2]string{"::ffff:10.0.0.0", "::ffff:10.1.255.255"}, "[::ffff:10.0.0.0/111]"},
+		{[2]string{"10.0.0.2", "10.0.0.1"}, "[]"},
+		{[2]string{"10.0.0.1", "::1"}, "[]"},
+	}
+	for _, mt := range tests {
+		r := RangeToPrefixes(netip.MustParseAddr(mt.Range[0]), netip.MustParseAddr(mt.Range[1]))
+		if fmt.Sprint(r) != mt.expected {
+			t.Error(mt.Range, r)
+		}
+	}
+}
+
+func TestPrefixToRange(t *testing.T) {
+	prepareExpected()
+	for _, mt := range test {
+		p, err := netip.ParsePrefix(mt.CIDR)
+		if err != nil {
+			continue
+		}
+		if _, ok := mt.Expected.([]string); !ok || p.Bits() == 0 {
+			continue
+		}
+		rg := strings.Split(mt.Range, "-")
+		from, to := PrefixToRange(p)
+		if from != netip.MustParseAddr(rg[0]) || to != netip.MustParseAddr(rg[1]) {
+			t.Error(mt.CIDR, from, to)
+		}
+	}
+	if from, to := PrefixToRange(netip.Prefix{}); from.IsValid() || to.IsValid() {
+		t.Error(from, to)
+	}
+}
+
+func TestPrefixCount(t *testing.T) {
+	tests := []struct {
+		p        string
+		expected string
+	}{
+		{"10.0.0.0/23", "512"},
+		{"10.0.0.1/32", "1"},
+		{"::/0", "340282366920938463463374607431768211456"},
+		{"2001:db8::/64", "18446744073709551616"},
+	}
+	for _, mt := range tests {
+		if n := PrefixCount(netip.MustParsePrefix(mt.p)); n.String() != mt.expected {
+			t.Error(mt.p, n)
+		}
+	}
+	if n := PrefixCount(netip.Prefix{}); n.Sign() != 0 {
+		t.Error(n)
+	}
+	pts, _ := ProcessPatterns("10.0.0.0/15")
+	if len(pts) != 2 || pts[0].Count().Int64() != 65536 {
+		t.Error(pts)
+	}
+}
+
+func TestExpand(t *testing.T) {
+	tests := []struct {
+		s        string
+		limit    int
+		expected string
+	}{
+		{"10.0.0.0/30", 4, "[10.0.0.0 10.0.0.1 10.0.0.2 10.0.0.3]"},
+		{"10.0.0.255-10.0.1.1", 10, "[10.0.0.255 10.0.1.0 10.0.1.1]"},
+		{"::fffe-::ffff", 2, "[::fffe ::ffff]"},
+		{"255.255.255.255", 1, "[255.255.255.255]"},
+		{"10.1.2.*", 256, "256"},
+	}
+	for _, mt := range tests {
+		r, err := Expand(mt.s, mt.limit)
+		if err != nil || fmt.Sprint(r) != mt.expected && fmt.Sprint(len(r)) != mt.expected {
+			t.Error(mt.s, r, err)
+		}
+	}
+	for _, s := range []string{"10.0.0.0/29", "::/0", "x"} {
+		if _, err := Expand(s, 4); err == nil {
+			t.Error(s)
+		}
+	}
+}
+
+func TestSplitRange(t *testing.T) {
+	tests := []struct {
+		Range    [2]string
+		n        int
+		expected string
+	}{
+		{[2]string{"10.0.0.0", "10.0.3.255"}, 4, "[{10.0.0.0 10.0.0.255} {10.0.1.0 10.0.1.255} {10.0.2.0 10.0.2.255} {10.0.3.0 10.0.3.255}]"},
+		{[2]string{"10.0.0.0", "10.0.0.9"}, 3, "[{10.0.0.0 10.0.0.3} {10.0.0.4 10.0.0.6} {10.0.0.7 10.0.0.9}]"},
+		{[2]string{"10.0.0.0", "10.0.0.1"}, 3, "[{10.0.0.0 10.0.0.0} {10.0.0.1 10.0.0.1}]"},
+		{[2]string{"::", "ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff"}, 2, "[{:: 7fff:ffff:ffff:ffff:ffff:ffff:ffff:ffff} {8000:: ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff}]"},
+	}
+	for _, mt := range tests {
+		r, err := SplitRange(netip.MustParseAddr(mt.Range[0]), netip.MustParseAddr(mt.Range[1]), mt.n)
+		if err != nil || fmt.Sprint(r) != mt.expected {
+			t.Error(mt.Range, r, err)
+		}
+	}
+	r, _ := SplitRange(netip.MustParseAddr("10.0.0.0"), netip.MustParseAddr("10.1.255.255"), 2)
+	if fmt.Sprint(r[1].Patterns()) != "[10.1.*]" {
+		t.Error(r)
+	}
+	if _, err := SplitRange(netip.MustParseAddr("10.0.0.1"), netip.MustParseAddr("10.0.0.0"), 2); err == nil {
+		t.Error("invalid range")
+	}
+	if _, err := SplitRange(netip.MustParseAddr("10.0.0.0"), netip.MustParseAddr("10.0.0.1"), 0); err == nil {
+		t.Error("invalid count")
+	}
+}
@@ -0,0 +1,111 @@
+// Copyright 2023-now by lifenjoiner. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE file.
+
+package iprefix
+
+import (
+	"net/netip"
+)
+
+// Class is the classification of the address space.
+type Class int
+
+const (
+	// ClassGlobal is globally routable.
+	ClassGlobal Class = iota
+	ClassPrivate
+	ClassShared
+	ClassLoopback
+	ClassLinkLocal
+	ClassMulticast
+	ClassDocumentation
+	// ClassReserved is the other special-purpose ones.
+	ClassReserved
+	// ClassMixed covers more than one class.
+	ClassMixed
+)
+
+var classNames = [...]string{"global", "private", "shared", "loopback", "link-local", "multicast", "documentation", "reserved", "mixed"}
+
+func (c Class) String() string {
+	if c < 0 || int(c) >= len(classNames) {
+		return "unknown"
+	}
+	return classNames[c]
+}
+
+type special struct {
+	p     netip.Prefix
+	class Class
+}
+
+// reserved is the special-purpose address space not globally routable,
+// RFC 6890 and updates.
+var reserved = []special{
+	// IPv4
+	{netip.MustParsePrefix("0.0.0.0/8"), ClassReserved},   // this network
+	{netip.MustParsePrefix("10.0.0.0/8"), ClassPrivate},   // RFC 1918
+	{netip.MustParsePrefix("100.64.0.0/10"), ClassShared}, // RFC 6598
+	{netip.MustParsePrefix("127.0.0.0/8"), ClassLoopback},
+	{netip.MustParsePrefix("169.254.0.0/16"), ClassLinkLocal},
+	{netip.MustParsePrefix("172.16.0.0/12"), ClassPrivate},         // RFC 1918
+	{netip.MustParsePrefix("192.0.0.0/24"), ClassReserved},         // IETF protocol assignments
+	{netip.MustParsePrefix("192.0.2.0/24"), ClassDocumentation},    // TEST-NET-1
+	{netip.MustParsePrefix("192.168.0.0/16"), ClassPrivate},        // RFC 1918
+	{netip.MustParsePrefix("198.18.0.0/15"), ClassReserved},        // benchmarking
+	{netip.MustParsePrefix("198.51.100.0/24"), ClassDocumentation}, // TEST-NET-2
+	{netip.MustParsePrefix("203.0.113.0/24"), ClassDocumentation},  // TEST-NET-3
+	{netip.MustParsePrefix("224.0.0.0/4"), ClassMulticast},
+	{netip.MustParsePrefix("240.0.0.0/4"), ClassReserved}, // and broadcast
+	// IPv6
+	{netip.MustParsePrefix("::/128"), ClassReserved}, // unspecified
+	{netip.MustParsePrefix("::1/128"), ClassLoopback},
+	{netip.MustParsePrefix("64:ff9b:1::/48"), ClassReserved}, // local-use IPv4/IPv6 translation
+	{netip.MustParsePrefix("100::/64"), ClassReserved},       // discard-only
+	{netip.MustParsePrefix("2001:db8::/32"), ClassDocumentation},
+	{netip.MustParsePrefix("3fff::/20"), ClassDocumentation}, // RFC 9637
+	{netip.MustParsePrefix("fc00::/7"), ClassPrivate},        // unique-local
+	{netip.MustParsePrefix("fe80::/10"), ClassLinkLocal},
+	{netip.MustParsePrefix("ff00::/8"), ClassMulticast},
+}
+
+// reservedRanges are the ranges of the reserved space, with the 4in6 ones of
+// the IPv4 ones, as Classify does.
+var reservedRanges = func() (rs []addrRange) {
+	for _, s := range reserved {
+		r := prefixRange(s.p)
+		rs = append(rs, r)
+		if r.from.Is4() {
+			rs = append(rs, addrRange{netip.AddrFrom16(r.from.As16()), netip.AddrFrom16(r.to.As16())})
+		}
+	}
+	return mergeRanges(rs)
+}()
+
+// Classify returns the class of the prefix. The 4in6 ones, in ::ffff:0:0/96,
+// are classified as the IPv4 ones they map.
+func Classify(p netip.Prefix) Class {
+	p = p.Masked()
+	if addr := p.Addr(); addr.Is4In6() && p.Bits() >= 96 {
+		p = netip.PrefixFrom(addr.Unmap(), p.Bits()-96)
+	}
+	mixed := false
+	for _, s := range reserved {
+		if s.p.Bits() <= p.Bits() && s.p.Contains(p.Addr()) {
+			return s.class
+		}
+		if s.p.Overlaps(p) {
+			mixed = true
+		}
+	}
+	if mixed {
+		return ClassMixed
+	}
+	return ClassGlobal
+}
+
+// Class returns the class of the prefix that the pattern stands for.
+func (pt Pattern) Class() Class {
+	return Classify(pt.Prefix)
+}
@@ -0,0 +1,49 @@
+// Copyright 2023-now by lifenjoiner. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE file.
+
+package iprefix
+
+import (
+	"net/netip"
+	"testing"
+)
+
+func TestClassify(t *testing.T) {
+	tests := []struct {
+		p        string
+		expected Class
+	}{
+		{"10.1.0.0/16", ClassPrivate},
+		{"10.0.0.0/8", ClassPrivate},
+		{"10.0.0.0/7", ClassMixed},
+		{"8.8.8.0/24", ClassGlobal},
+		{"127.0.0.1/32", ClassLoopback},
+		{"169.254.1.0/24", ClassLinkLocal},
+		{"239.0.0.0/8", ClassMulticast},
+		{"100.64.0.0/16", ClassShared},
+		{"198.51.100.7/32", ClassDocumentation},
+		{"255.255.255.255/32", ClassReserved},
+		{"::ffff:192.168.1.0/120", ClassPrivate},
+		{"::ffff:8.8.8.8/128", ClassGlobal},
+		{"::ffff:0:0/96", ClassMixed},
+		{"2001:db8:1::/48", ClassDocumentation},
+		{"fd00::/8", ClassPrivate},
+		{"fe00::/8", ClassMixed},
+		{"2400::/16", ClassGlobal},
+	}
+	for _, mt := range tests {
+		if c := Classify(netip.MustParsePrefix(mt.p)); c != mt.expected {
+			t.Error(mt.p, c)
+		}
+	}
+
+	pts, _ := ProcessPatterns("172.15.255.254-172.16.0.1")
+	classes := ""
+	for _, pt := range pts {
+		classes += pt.Class().String() + " "
+	}
+	if classes != "global global private private " {
+		t.Error(classes)
+	}
+}
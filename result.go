@@ -0,0 +1,88 @@
+// Copyright 2023-now by lifenjoiner. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE file.
+
+package iprefix
+
+import (
+	"errors"
+	"math/big"
+	"net/netip"
+	"strings"
+)
+
+// Result is the patterns of an input, marshaling to JSON like:
+//
+//	{"input":"10.0.0.0/23","family":4,"patterns":["10.0.0.*","10.0.1.*"],"count":512}
+type Result struct {
+	Input    string   `json:"input"`
+	Family   int      `json:"family"`
+	Patterns []string `json:"patterns"`
+	// Count is the count of IPs covered, the excluded ones not.
+	Count *big.Int `json:"count"`
+	// Prefixes is the least CIDRs of the IPs covered.
+	Prefixes []netip.Prefix `json:"-"`
+	// Err is the error of the input in a batch, with nil Patterns.
+	Err error `json:"-"`
+}
+
+// ProcessResult generates the Result of a CIDR, an IP range `IP1-IP2` or an
+// IP, tuned by `opts`.
+func ProcessResult(s string, opts ...Option) (*Result, error) {
+	r, err := parseEntry(s)
+	if err != nil {
+		return nil, err
+	}
+	ps, err := Process(s, opts...)
+	if err != nil {
+		return nil, err
+	}
+	family := 6
+	if r.from.Is4() {
+		family = 4
+	}
+	rs := newOptions(opts).ranges([]addrRange{r})
+	n := new(big.Int)
+	for _, x := range rs {
+		n.Add(n, x.count())
+	}
+	return &Result{Input: s, Family: family, Patterns: ps, Count: n, Prefixes: rangesPrefixes(rs)}, nil
+}
+
+// lineEntry returns the entry of a line, the first field or the first two
+// fields for an address and wildcard mask pair, or "" for a blank or comment
+// line.
+func lineEntry(line string) string {
+	f := strings.Fields(line)
+	if len(f) == 0 || strings.HasPrefix(f[0], "#") {
+		return ""
+	}
+	if len(f) > 1 {
+		if _, err := ParseWildcardMask(f[0] + " " + f[1]); err == nil {
+			return f[0] + " " + f[1]
+		}
+	}
+	return f[0]
+}
+
+// ProcessAll generates the Results of lines, tuned by `opts`. The entry of a
+// line is its first field, the trailing ones are comments. Blank lines and the
+// ones starting with `#` are skipped. An invalid entry doesn't stop the others,
+// its EntryError is in its Result and joined into the returned one.
+func ProcessAll(lines []string, opts ...Option) (rs []Result, err error) {
+	var errs []error
+	for i, line := range lines {
+		s := lineEntry(line)
+		if s == "" {
+			continue
+		}
+		r, e := ProcessResult(s, opts...)
+		if e != nil {
+			e = &EntryError{s, i + 1, e}
+			errs = append(errs, e)
+			r = &Result{Input: s, Err: e}
+		}
+		rs = append(rs, *r)
+	}
+	return rs, errors.Join(errs...)
+}
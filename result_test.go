@@ -0,0 +1,107 @@
+// Copyright 2023-now by lifenjoiner. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE file.
+
+package iprefix
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/netip"
+	"strings"
+	"testing"
+)
+
+func TestProcessResult(t *testing.T) {
+	tests := []struct {
+		s        string
+		expected string
+	}{
+		{"10.0.0.0/23", `{"input":"10.0.0.0/23","family":4,"patterns":["10.0.0.*","10.0.1.*"],"count":512}`},
+		{"10.0.0.1", `{"input":"10.0.0.1","family":4,"patterns":["10.0.0.1"],"count":1}`},
+		{"::-::1", `{"input":"::-::1","family":6,"patterns":["::","::1"],"count":2}`},
+		{"10.1.*", `{"input":"10.1.*","family":4,"patterns":["10.1.*"],"count":65536}`},
+		{"::/0", ""},
+	}
+	for _, mt := range tests {
+		r, err := ProcessResult(mt.s, Limit(2))
+		if err != nil {
+			t.Error(mt.s, err)
+			continue
+		}
+		b, err := json.Marshal(r)
+		if err != nil {
+			t.Error(mt.s, err)
+			continue
+		}
+		if len(mt.expected) == 0 {
+			if r.Count.BitLen() != 129 {
+				t.Error(mt.s, r.Count)
+			}
+			continue
+		}
+		if string(b) != mt.expected {
+			t.Error(mt.s, string(b))
+		}
+	}
+	if _, err := ProcessResult("10.0.0.0/33"); err == nil {
+		t.Error("10.0.0.0/33")
+	}
+
+	// without the excluded IPs
+	r, err := ProcessResult("10.0.0.0/22", Exclude(netip.MustParsePrefix("10.0.1.0/24")))
+	if err != nil || r.Count.Int64() != 768 || fmt.Sprint(r.Prefixes) != "[10.0.0.0/24 10.0.2.0/23]" ||
+		strings.Join(r.Patterns, " ") != "10.0.0.* 10.0.2.* 10.0.3.*" {
+		t.Error(r, err)
+	}
+}
+
+func TestProcessAll(t *testing.T) {
+	rs, err := ProcessAll([]string{
+		"# comment",
+		"",
+		"10.0.0.0/23 office",
+		"\t10.0.1.0 0.0.0.255 acl",
+		"10.0.0.0/33",
+		"::1",
+		"x",
+	})
+	if err == nil {
+		t.Error("no error")
+	}
+	expected := []struct {
+		input string
+		n     int
+		err   bool
+	}{
+		{"10.0.0.0/23", 2, false},
+		{"10.0.1.0 0.0.0.255", 1, false},
+		{"10.0.0.0/33", 0, true},
+		{"::1", 1, false},
+		{"x", 0, true},
+	}
+	if len(rs) != len(expected) {
+		t.Fatal(rs)
+	}
+	for i, e := range expected {
+		r := rs[i]
+		if r.Input != e.input || len(r.Patterns) != e.n || (r.Err != nil) != e.err {
+			t.Error(r)
+		}
+	}
+
+	if _, err = ProcessAll([]string{"10.0.0.0/8", "# x"}); err != nil {
+		t.Error(err)
+	}
+}
+
+func BenchmarkProcessAll(b *testing.B) {
+	var lines []string
+	for i := range 256 {
+		lines = append(lines, fmt.Sprintf("10.%d.0.3-10.%d.2.200", i, i), fmt.Sprintf("2001:db8:%x::/120", i))
+	}
+	b.ResetTimer()
+	for range b.N {
+		ProcessAll(lines)
+	}
+}
@@ -0,0 +1,70 @@
+// Copyright 2023-now by lifenjoiner. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE file.
+
+package iprefix
+
+import (
+	"math/big"
+	"math/rand"
+	"net/netip"
+)
+
+// addrAdd returns `addr` + `k`, `k` must keep it in the same type.
+func addrAdd(addr netip.Addr, k *big.Int) netip.Addr {
+	ip := addr.AsSlice()
+	n := new(big.Int).SetBytes(ip)
+	n.Add(n, k).FillBytes(ip)
+	r, _ := netip.AddrFromSlice(ip)
+	return r
+}
+
+// sample picks `n` distinct IPs of the range randomly, or all of them if not
+// more than `n`.
+func (r addrRange) sample(n int, rnd *rand.Rand) (addrs []netip.Addr) {
+	c := r.count()
+	if c.Cmp(big.NewInt(int64(n))) <= 0 {
+		for addr := r.from; ; addr = addr.Next() {
+			addrs = append(addrs, addr)
+			if addr == r.to {
+				return
+			}
+		}
+	}
+	bits := c.BitLen()
+	buf := make([]byte, (bits+7)/8)
+	seen := make(map[netip.Addr]bool, n)
+	for len(addrs) < n {
+		rnd.Read(buf)
+		buf[0] &= byte(0xff >> (len(buf)*8 - bits))
+		k := new(big.Int).SetBytes(buf)
+		if k.Cmp(c) >= 0 {
+			continue
+		}
+		addr := addrAdd(r.from, k)
+		if !seen[addr] {
+			seen[addr] = true
+			addrs = append(addrs, addr)
+		}
+	}
+	return
+}
+
+// Sample picks `n` distinct IPs of the prefix randomly, repeatable by `seed`.
+// All the IPs are returned if not more than `n`.
+func Sample(p netip.Prefix, n int, seed int64) []netip.Addr {
+	if !p.IsValid() || n <= 0 {
+		return nil
+	}
+	return prefixRange(p).sample(n, rand.New(rand.NewSource(seed)))
+}
+
+// SampleRange picks `n` distinct IPs of the IP range randomly, repeatable by
+// `seed`. All the IPs are returned if not more than `n`.
+// It returns nil for an invalid range.
+func SampleRange(start, end netip.Addr, n int, seed int64) []netip.Addr {
+	if checkRange(start, end) != nil || n <= 0 {
+		return nil
+	}
+	return addrRange{start, end}.sample(n, rand.New(rand.NewSource(seed)))
+}
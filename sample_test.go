@@ -0,0 +1,59 @@
+// Copyright 2023-now by lifenjoiner. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE file.
+
+package iprefix
+
+import (
+	"fmt"
+	"net/netip"
+	"testing"
+)
+
+func TestSample(t *testing.T) {
+	tests := []struct {
+		p string
+		n int
+	}{
+		{"10.0.0.0/8", 5},
+		{"10.0.0.0/30", 4},
+		{"2001:db8::/32", 5},
+		{"::/0", 5},
+		{"::ffff:10.0.0.0/120", 5},
+	}
+	for _, mt := range tests {
+		s := mt.p
+		p := netip.MustParsePrefix(s)
+		r := Sample(p, 5, 1)
+		if fmt.Sprint(r) != fmt.Sprint(Sample(p, 5, 1)) {
+			t.Error(s, "not repeatable")
+		}
+		seen := map[netip.Addr]bool{}
+		for _, addr := range r {
+			if !p.Contains(addr) || seen[addr] {
+				t.Error(s, r)
+			}
+			seen[addr] = true
+		}
+		if len(r) != mt.n {
+			t.Error(s, r)
+		}
+	}
+	if Sample(netip.Prefix{}, 5, 1) != nil {
+		t.Error("invalid prefix")
+	}
+
+	r := SampleRange(netip.MustParseAddr("10.0.0.250"), netip.MustParseAddr("10.0.1.5"), 20, 2)
+	if len(r) != 12 || r[0].String() != "10.0.0.250" || r[11].String() != "10.0.1.5" {
+		t.Error(r)
+	}
+	r = SampleRange(netip.MustParseAddr("10.0.0.250"), netip.MustParseAddr("10.0.1.5"), 3, 2)
+	for _, addr := range r {
+		if addr.Less(netip.MustParseAddr("10.0.0.250")) || netip.MustParseAddr("10.0.1.5").Less(addr) {
+			t.Error(r)
+		}
+	}
+	if SampleRange(netip.MustParseAddr("10.0.0.2"), netip.MustParseAddr("10.0.0.1"), 3, 2) != nil {
+		t.Error("invalid range")
+	}
+}
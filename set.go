@@ -0,0 +1,423 @@
+// Copyright 2023-now by lifenjoiner. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE file.
+
+package iprefix
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"net/netip"
+	"slices"
+	"strings"
+)
+
+// parseEntry parses a pattern, a CIDR, an IP range `IP1-IP2`, an address and
+// wildcard mask pair or an IP.
+func parseEntry(s string) (r addrRange, err error) {
+	switch {
+	case strings.ContainsRune(s, '/'):
+		var p netip.Prefix
+		if p, err = parsePrefix(s); err != nil {
+			return
+		}
+		r = prefixRange(p)
+	case strings.ContainsRune(s, ' '):
+		var p netip.Prefix
+		if p, err = ParseWildcardMask(s); err != nil {
+			return
+		}
+		r = prefixRange(p)
+	case strings.ContainsRune(s, '-'):
+		x := strings.SplitN(s, "-", 2)
+		r.from, r.to, err = parseRange(x[0], x[1])
+	default:
+		var p netip.Prefix
+		if p, err = ParsePattern(s); err != nil {
+			return
+		}
+		r = prefixRange(p)
+	}
+	return
+}
+
+// PatternSet is a set of IPs covered by patterns, CIDRs or IP ranges.
+type PatternSet struct {
+	ranges []addrRange
+}
+
+// exactPattern checks that an IPv6 pattern matches by its text exactly the
+// IPs of the range `r` it stands for, see ParsePattern.
+func exactPattern(s string, r addrRange) error {
+	if !strings.HasSuffix(s, "*") || !strings.ContainsRune(s, ':') || strings.ContainsRune(s, '.') {
+		return nil
+	}
+	if !strings.Contains(s, "::") && !slices.Contains(strings.Split(s, ":"), "0") {
+		// no zero blocks, every text of the prefix starts so
+		return nil
+	}
+	x, y := newTextSet(), newTextSet()
+	if _, err := x.addPattern(s); err != nil {
+		return err
+	}
+	y.addRanges([]addrRange{r})
+	if !x.merge().equal(y.merge()) {
+		return fmt.Errorf("%w: %q", ErrAmbiguousPattern, s)
+	}
+	return nil
+}
+
+// parseEntries parses the entries to merged ranges. The ambiguous patterns,
+// see exactPattern, are taken if the entries match exactly the IPs of the
+// ranges from the first to the last IP of each, like all the IPv6 spellings
+// of a prefix.
+func parseEntries(ss []string) ([]addrRange, error) {
+	var rs []addrRange
+	var ambiguous []int
+	for i, s := range ss {
+		r, err := parseEntry(s)
+		if err == nil {
+			err = exactPattern(s, r)
+		}
+		switch {
+		case errors.Is(err, ErrAmbiguousPattern):
+			ambiguous = append(ambiguous, i)
+		case err != nil:
+			return nil, &EntryError{s, i + 1, err}
+		default:
+			rs = append(rs, r)
+		}
+	}
+	rs = mergeRanges(rs)
+	if len(ambiguous) == 0 {
+		return rs, nil
+	}
+	matched := newTextSet()
+	matched.addRanges(rs)
+	hulls := make([]addrRange, len(ambiguous))
+	for j, i := range ambiguous {
+		hulls[j], _ = matched.addPattern(ss[i])
+	}
+	all := mergeRanges(append(slices.Clone(rs), hulls...))
+	missing := newTextSet()
+	missing.addRanges(all)
+	if missing = missing.merge().subtract(matched.merge()); !missing.empty() {
+		for j, i := range ambiguous {
+			hull := newTextSet()
+			hull.addRanges(hulls[j : j+1])
+			if hull.merge().intersects(missing) {
+				return nil, &EntryError{ss[i], i + 1, fmt.Errorf("%w: %q", ErrAmbiguousPattern, ss[i])}
+			}
+		}
+	}
+	return all, nil
+}
+
+// NewPatternSet builds a PatternSet from patterns, CIDRs, IP ranges `IP1-IP2`
+// or IPs. The IPv6 patterns matching by their text other IPs than the ones of
+// the prefixes they stand for, see ParsePattern, are taken only if all the
+// entries match exactly the IPs of ranges, like all the IPv6 spellings of a
+// prefix, or else the error wraps ErrAmbiguousPattern.
+func NewPatternSet(ss []string) (*PatternSet, error) {
+	rs, err := parseEntries(ss)
+	if err != nil {
+		return nil, err
+	}
+	return &PatternSet{rs}, nil
+}
+
+// Add adds a pattern, a CIDR, an IP range `IP1-IP2` or an IP to the set. An
+// ambiguous pattern fails, see NewPatternSet.
+func (set *PatternSet) Add(s string) error {
+	r, err := parseEntry(s)
+	if err == nil {
+		err = exactPattern(s, r)
+	}
+	if err != nil {
+		return err
+	}
+	set.ranges = mergeRanges(append(set.ranges, r))
+	return nil
+}
+
+// Contains reports whether `addr` is in the set.
+func (set *PatternSet) Contains(addr netip.Addr) bool {
+	return searchRanges(set.ranges, addr) >= 0
+}
+
+// Prefixes returns the least prefixes covering the set, in address order.
+func (set *PatternSet) Prefixes() []netip.Prefix {
+	return rangesPrefixes(set.ranges)
+}
+
+// MarshalText encodes the set as its least CIDRs separated by commas.
+func (set *PatternSet) MarshalText() ([]byte, error) {
+	var b []byte
+	for i, p := range set.Prefixes() {
+		if i > 0 {
+			b = append(b, ',')
+		}
+		b = p.AppendTo(b)
+	}
+	return b, nil
+}
+
+// UnmarshalText replaces the set with patterns, CIDRs, IP ranges `IP1-IP2` or
+// IPs separated by commas or newlines.
+func (set *PatternSet) UnmarshalText(text []byte) error {
+	ss := strings.FieldsFunc(string(text), func(r rune) bool {
+		return r == ',' || r == '\n'
+	})
+	var entries []string
+	for _, s := range ss {
+		if s = strings.TrimSpace(s); s != "" {
+			entries = append(entries, s)
+		}
+	}
+	x, err := NewPatternSet(entries)
+	if err != nil {
+		return err
+	}
+	*set = *x
+	return nil
+}
+
+// Aggregate merges the overlapping or adjacent patterns, CIDRs, IP ranges
+// `IP1-IP2` or IPs into the least prefixes.
+func Aggregate(ss []string) ([]netip.Prefix, error) {
+	set, err := NewPatternSet(ss)
+	if err != nil {
+		return nil, err
+	}
+	return rangesPrefixes(set.ranges), nil
+}
+
+// Subtract generates string IP prefix pattern from `universe` excluding
+// `exclusions`. They are patterns, CIDRs, IP ranges `IP1-IP2` or IPs.
+func Subtract(universe, exclusions []string) ([]string, error) {
+	u, err := NewPatternSet(universe)
+	if err != nil {
+		return nil, err
+	}
+	x, err := NewPatternSet(exclusions)
+	if err != nil {
+		return nil, err
+	}
+	return rangesPatterns(subtractRanges(u.ranges, x.ranges)), nil
+}
+
+// Intersect generates string IP prefix pattern from the overlapping of `a`
+// and `b`. They are patterns, CIDRs, IP ranges `IP1-IP2` or IPs.
+func Intersect(a, b []string) ([]string, error) {
+	x, err := NewPatternSet(a)
+	if err != nil {
+		return nil, err
+	}
+	y, err := NewPatternSet(b)
+	if err != nil {
+		return nil, err
+	}
+	return rangesPatterns(intersectRanges(x.ranges, y.ranges)), nil
+}
+
+// Equal reports whether the sets cover the same IPs.
+func (set *PatternSet) Equal(other *PatternSet) bool {
+	return slices.Equal(set.ranges, other.ranges)
+}
+
+// EqualSets reports whether `a` and `b` cover the same IPs, however they are
+// spelled. They are patterns, CIDRs, IP ranges `IP1-IP2` or IPs, taken as
+// NewPatternSet does. See EqualPatternTexts for the IPs matched by text.
+func EqualSets(a, b []string) (bool, error) {
+	x, err := NewPatternSet(a)
+	if err != nil {
+		return false, err
+	}
+	y, err := NewPatternSet(b)
+	if err != nil {
+		return false, err
+	}
+	return x.Equal(y), nil
+}
+
+// DiffSets generates string IP prefix pattern of the coverage `added` in `b`
+// and `removed` from `a`. They are patterns, CIDRs, IP ranges `IP1-IP2` or IPs,
+// taken as NewPatternSet does. See DiffPatternTexts for the IPs matched by
+// text.
+func DiffSets(a, b []string) (added, removed []string, err error) {
+	x, err := NewPatternSet(a)
+	if err != nil {
+		return
+	}
+	y, err := NewPatternSet(b)
+	if err != nil {
+		return
+	}
+	added = rangesPatterns(subtractRanges(y.ranges, x.ranges))
+	removed = rangesPatterns(subtractRanges(x.ranges, y.ranges))
+	return
+}
+
+// EqualPatternTexts reports whether `a` and `b` match by text the same IPs,
+// whose canonical text starts with the text before the wildcard. They are
+// patterns, CIDRs, IP ranges `IP1-IP2` or IPs, the CIDRs, ranges and IPs taken
+// as the patterns generated from them, so any generated file compares equal to
+// its input, although an IPv6 pattern may match more IPs, see ParsePattern.
+func EqualPatternTexts(a, b []string) (bool, error) {
+	x, err := entriesTextSet(a)
+	if err != nil {
+		return false, err
+	}
+	y, err := entriesTextSet(b)
+	if err != nil {
+		return false, err
+	}
+	return x.equal(y), nil
+}
+
+// DiffPatternTexts generates the patterns matching by text exactly the IPs
+// `added` in `b` and `removed` from `a`, sorted by ComparePatterns. They are
+// taken as EqualPatternTexts does.
+func DiffPatternTexts(a, b []string) (added, removed []string, err error) {
+	x, err := entriesTextSet(a)
+	if err != nil {
+		return
+	}
+	y, err := entriesTextSet(b)
+	if err != nil {
+		return
+	}
+	added = collect(0, y.subtract(x).patterns)
+	removed = collect(0, x.subtract(y).patterns)
+	SortPatterns(added)
+	SortPatterns(removed)
+	return
+}
+
+// VerifyCover checks that the patterns, CIDRs, IP ranges `IP1-IP2` or IPs
+// cover exactly the range from `start` to `end`, taken as NewPatternSet does.
+// The error wraps ErrCoverMismatch and tells the first gap or spillover. See
+// VerifyPatternTexts for the IPs matched by text.
+func VerifyCover(patterns []string, start, end netip.Addr) error {
+	if err := checkRange(start, end); err != nil {
+		return err
+	}
+	set, err := NewPatternSet(patterns)
+	if err != nil {
+		return err
+	}
+	want := []addrRange{{start, end}}
+	if gaps := subtractRanges(want, set.ranges); len(gaps) > 0 {
+		return fmt.Errorf("%w: gap %v - %v", ErrCoverMismatch, gaps[0].from, gaps[0].to)
+	}
+	if spills := subtractRanges(set.ranges, want); len(spills) > 0 {
+		return fmt.Errorf("%w: spillover %v - %v", ErrCoverMismatch, spills[0].from, spills[0].to)
+	}
+	return nil
+}
+
+// VerifyPatternTexts checks that the patterns, CIDRs, IP ranges `IP1-IP2` or
+// IPs match by text exactly the IPs that the patterns generated from `start`
+// to `end` match, taken as EqualPatternTexts does. The error wraps
+// ErrCoverMismatch and tells a pattern of the first gap or spillover.
+func VerifyPatternTexts(patterns []string, start, end netip.Addr) error {
+	if err := checkRange(start, end); err != nil {
+		return err
+	}
+	set, err := entriesTextSet(patterns)
+	if err != nil {
+		return err
+	}
+	want := newTextSet()
+	processRange(start, end, IPv6All, func(p string) bool {
+		want.addPattern(p)
+		return true
+	})
+	want.merge()
+	if p := firstPattern(want.subtract(set)); p != "" {
+		return fmt.Errorf("%w: gap %s", ErrCoverMismatch, p)
+	}
+	if p := firstPattern(set.subtract(want)); p != "" {
+		return fmt.Errorf("%w: spillover %s", ErrCoverMismatch, p)
+	}
+	return nil
+}
+
+// PatternTextRange returns the range from the first to the last IP that a
+// pattern matches by text, and the count of them, see EqualPatternTexts.
+func PatternTextRange(s string) (r Range, n *big.Int, err error) {
+	ts := newTextSet()
+	hull, err := ts.addPattern(s)
+	if err != nil {
+		return
+	}
+	n = new(big.Int)
+	for _, rs := range ts.merge() {
+		for _, x := range rs {
+			n.Add(n, x.count())
+		}
+	}
+	return Range{hull.from, hull.to}, n, nil
+}
+
+// OverlapPatternTexts reports whether `a` and `b` share some IPs. They are
+// patterns, taken by the IPs they match by text, CIDRs, IP ranges `IP1-IP2`
+// or IPs, taken by their IPs.
+func OverlapPatternTexts(a, b string) (bool, error) {
+	var sets [2]textSet
+	for i, s := range []string{a, b} {
+		sets[i] = newTextSet()
+		if strings.HasSuffix(s, "*") {
+			if _, err := sets[i].addPattern(s); err != nil {
+				return false, err
+			}
+			continue
+		}
+		r, err := parseEntry(s)
+		if err != nil {
+			return false, err
+		}
+		sets[i].addRanges([]addrRange{r})
+	}
+	return sets[0].merge().intersects(sets[1].merge()), nil
+}
+
+// firstPattern returns the first pattern of the IPs of `ts`, or "" if none.
+func firstPattern(ts textSet) (first string) {
+	ts.patterns(func(p string) bool {
+		first = p
+		return false
+	})
+	return
+}
+
+// ProcessMany generates string IP prefix pattern from the union of patterns,
+// CIDRs, IP ranges `IP1-IP2` or IPs, without duplicates, tuned by `opts`.
+func ProcessMany(inputs []string, opts ...Option) ([]string, error) {
+	set, err := NewPatternSet(inputs)
+	if err != nil {
+		return nil, err
+	}
+	o := newOptions(opts)
+	return o.output(o.rangesGenerator(set.ranges))
+}
+
+// Count returns the count of IPs in the set.
+func (set *PatternSet) Count() *big.Int {
+	n := new(big.Int)
+	for _, r := range set.ranges {
+		n.Add(n, r.count())
+	}
+	return n
+}
+
+// Count returns the count of IPs covered by patterns, CIDRs, IP ranges
+// `IP1-IP2` or IPs, the overlapping ones counted once.
+func Count(ss []string) (*big.Int, error) {
+	set, err := NewPatternSet(ss)
+	if err != nil {
+		return nil, err
+	}
+	return set.Count(), nil
+}
@@ -0,0 +1,434 @@
+// Copyright 2023-now by lifenjoiner. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE file.
+
+package iprefix
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/netip"
+	"strings"
+	"testing"
+)
+
+func TestPatternSet(t *testing.T) {
+	set, err := NewPatternSet([]string{"10.*", "192.168.1.0/24", "172.16.0.1-172.16.0.9", "::ffff:10.1.*", "2001:20:*", "8.8.8.8"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = set.Add("192.168.2.*"); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		ip       string
+		expected bool
+	}{
+		{"10.2.3.4", true},
+		{"11.0.0.0", false},
+		{"9.255.255.255", false},
+		{"192.168.1.255", true},
+		{"192.168.2.1", true},
+		{"192.168.3.0", false},
+		{"172.16.0.0", false},
+		{"172.16.0.9", true},
+		{"8.8.8.8", true},
+		{"8.8.4.4", false},
+		{"::ffff:10.1.0.1", true},
+		{"::ffff:10.2.0.1", false},
+		{"2001:20::1", true},
+		{"2001:21::1", false},
+	}
+	for _, mt := range tests {
+		if set.Contains(netip.MustParseAddr(mt.ip)) != mt.expected {
+			t.Error(mt.ip)
+		}
+	}
+
+	if _, err = NewPatternSet([]string{"10.0.0.0/33"}); err == nil {
+		t.Error("10.0.0.0/33")
+	}
+	if err = set.Add("1.2.3.4-1.2.3.3"); err == nil {
+		t.Error("1.2.3.4-1.2.3.3")
+	}
+	if err = set.Add("10.0.0.0 ::ff"); !errors.Is(err, ErrInvalidCIDR) {
+		t.Error("10.0.0.0 ::ff", err)
+	}
+
+	// the ambiguous spellings are taken together
+	for _, mt := range []struct {
+		ss       []string
+		expected string
+	}{
+		{[]string{"2001:db8:0:*", "2001:db8::*", "2001:db8::"}, "2001:db8::/48"},
+		{[]string{"::*", "0:*", "::"}, "::/16"},
+		{[]string{"1111::*", "1111::/32"}, "1111::/32"},
+	} {
+		if set, err = NewPatternSet(mt.ss); err != nil || fmt.Sprint(set.Prefixes()) != "["+mt.expected+"]" {
+			t.Error(mt.ss, set, err)
+		}
+	}
+	for _, ss := range [][]string{{"1111::4444:*"}, {"2001:db8:0:*"}, {"10.*", "::ffff:*"}} {
+		var ee *EntryError
+		if _, err = NewPatternSet(ss); !errors.As(err, &ee) || !errors.Is(err, ErrAmbiguousPattern) || ee.Input != ss[len(ss)-1] {
+			t.Error(ss, err)
+		}
+	}
+	if err = set.Add("2001:db8::*"); !errors.Is(err, ErrAmbiguousPattern) {
+		t.Error(err)
+	}
+}
+
+func TestAggregate(t *testing.T) {
+	tests := []struct {
+		ss       []string
+		expected []string
+	}{
+		{[]string{"10.0.0.0/24", "10.0.1.0/24", "10.0.0.128/25"}, []string{"10.0.0.0/23"}},
+		{[]string{"10.0.0.1-10.0.0.6", "10.0.0.7"}, []string{"10.0.0.1/32", "10.0.0.2/31", "10.0.0.4/30"}},
+		{[]string{"0.0.0.0/1", "128.0.0.0/1", "::/0"}, []string{"0.0.0.0/0", "::/0"}},
+		{[]string{"2001:db8::/33", "2001:db8:8000::/33", "10.1.*"}, []string{"10.1.0.0/16", "2001:db8::/32"}},
+		{[]string{"255.255.255.255", "255.255.255.254"}, []string{"255.255.255.254/31"}},
+	}
+	for _, mt := range tests {
+		r, err := Aggregate(mt.ss)
+		if err != nil || len(r) != len(mt.expected) {
+			t.Error(mt.ss, r, err)
+			continue
+		}
+		for i, p := range r {
+			if p.String() != mt.expected[i] {
+				t.Error(mt.ss, r)
+				break
+			}
+		}
+	}
+	if _, err := Aggregate([]string{"x"}); err == nil {
+		t.Error("x")
+	}
+}
+
+func TestSubtract(t *testing.T) {
+	tests := []struct {
+		universe   []string
+		exclusions []string
+		expected   []string
+	}{
+		{[]string{"10.0.0.0/14"}, []string{"10.2.0.0/16"}, []string{"10.0.*", "10.1.*", "10.3.*"}},
+		{[]string{"10.0.0.0/30", "::/127"}, []string{"10.0.0.1-10.0.0.2", "::1"}, []string{"10.0.0.0", "10.0.0.3", "::"}},
+		{[]string{"10.0.0.0/24"}, []string{"10.*"}, nil},
+		{[]string{"10.0.0.0/31"}, []string{"::/0", "9.0.0.0/8"}, []string{"10.0.0.0", "10.0.0.1"}},
+	}
+	for _, mt := range tests {
+		r, err := Subtract(mt.universe, mt.exclusions)
+		if err != nil || !validate(r, mt.expected) {
+			t.Error(mt.universe, mt.exclusions, r, err)
+		}
+	}
+	if _, err := Subtract([]string{"10.*"}, []string{"x"}); err == nil {
+		t.Error("x")
+	}
+}
+
+func TestIntersect(t *testing.T) {
+	tests := []struct {
+		a, b     []string
+		expected []string
+	}{
+		{[]string{"10.0.0.0/8", "172.16.0.0/12", "192.168.0.0/16"}, []string{"172.31.0.0-172.32.255.255"}, []string{"172.31.*"}},
+		{[]string{"10.0.0.0/30", "::/126"}, []string{"10.0.0.2-10.0.0.9", "::1-::2"}, []string{"10.0.0.2", "10.0.0.3", "::1", "::2"}},
+		{[]string{"10.0.0.0/24"}, []string{"::/0", "11.*"}, nil},
+		{[]string{"10.*"}, []string{"10.1.*", "10.3.*"}, []string{"10.1.*", "10.3.*"}},
+	}
+	for _, mt := range tests {
+		r, err := Intersect(mt.a, mt.b)
+		if err != nil || !validate(r, mt.expected) {
+			t.Error(mt.a, mt.b, r, err)
+		}
+	}
+	if _, err := Intersect([]string{"x"}, nil); err == nil {
+		t.Error("x")
+	}
+}
+
+func TestProcessMany(t *testing.T) {
+	r, err := ProcessMany([]string{"10.0.0.0/16", "10.0.0.0/24", "10.1.0.0-10.1.255.255", "10.0.3.4", "::1", "::/127"})
+	if err != nil || !validate(r, []string{"10.0.*", "10.1.*", "::", "::1"}) {
+		t.Error(r, err)
+	}
+	r, err = ProcessMany([]string{"10.0.1.0/24", "10.0.0.0/24", "10.1.0.0/16", "10.0.2.0-10.0.255.255"}, Minimize(), Sort(OrderAddress))
+	if err != nil || strings.Join(r, " ") != "10.0.* 10.1.*" {
+		t.Error(r, err)
+	}
+	if _, err = ProcessMany([]string{"10.0.0.0/16", "x"}); err == nil {
+		t.Error("x")
+	}
+}
+
+func TestCount(t *testing.T) {
+	tests := []struct {
+		ss       []string
+		expected string
+	}{
+		{[]string{"10.*", "10.1.*", "10.0.0.0/7"}, "33554432"},
+		{[]string{"10.0.0.1-10.0.0.6", "10.0.0.6", "::/127"}, "8"},
+		{[]string{"::/0", "0.0.0.0/0"}, "340282366920938463463374607436063178752"},
+		{nil, "0"},
+	}
+	for _, mt := range tests {
+		n, err := Count(mt.ss)
+		if err != nil || n.String() != mt.expected {
+			t.Error(mt.ss, n, err)
+		}
+	}
+	if _, err := Count([]string{"x"}); err == nil {
+		t.Error("x")
+	}
+}
+
+func TestEqualSets(t *testing.T) {
+	tests := []struct {
+		a, b     []string
+		expected bool
+	}{
+		{[]string{"10.0.0.*", "10.0.1.*"}, []string{"10.0.0.0/23"}, true},
+		{[]string{"10.0.0.0-10.0.0.127", "10.0.0.128/25"}, []string{"10.0.0.*"}, true},
+		{[]string{"2001:db8:0:*", "2001:db8::*"}, []string{"2001:db8::/48"}, true},
+		{[]string{"10.0.0.*"}, []string{"10.0.0.0/23"}, false},
+		{[]string{"::ffff:10.*"}, []string{"10.*"}, false},
+		{nil, nil, true},
+	}
+	for _, mt := range tests {
+		eq, err := EqualSets(mt.a, mt.b)
+		if err != nil || eq != mt.expected {
+			t.Error(mt.a, mt.b, eq, err)
+		}
+	}
+	if _, err := EqualSets([]string{"x"}, nil); err == nil {
+		t.Error("x")
+	}
+	// all the IPv6 spellings of prefixes
+	for _, s := range []string{"2001:db8::/33", "1111::/31", "1:2:3:4:5:6:7:8/126"} {
+		ps, _ := ProcessCIDR(s)
+		if eq, err := EqualSets([]string{s}, ps); err != nil || !eq {
+			t.Error(s, ps, err)
+		}
+	}
+	if _, err := EqualSets([]string{"1111::4444:*"}, nil); !errors.Is(err, ErrAmbiguousPattern) {
+		t.Error(err)
+	}
+}
+
+func TestEqualPatternTexts(t *testing.T) {
+	tests := []struct {
+		a, b     []string
+		expected bool
+	}{
+		{[]string{"10.0.0.*", "10.0.1.*"}, []string{"10.0.0.0/23"}, true},
+		{[]string{"2001:db8:0:*", "2001:db8::*"}, []string{"2001:db8::/48"}, true},
+		{[]string{"1111::4444:*"}, []string{"1111:0:0:0:4444::/80"}, true},
+		{[]string{"1::4444:*"}, []string{"1:0:0:4444::/64"}, false},
+		{[]string{"::ffff:10.*"}, []string{"10.*"}, false},
+		{nil, nil, true},
+	}
+	for _, mt := range tests {
+		eq, err := EqualPatternTexts(mt.a, mt.b)
+		if err != nil || eq != mt.expected {
+			t.Error(mt.a, mt.b, eq, err)
+		}
+	}
+
+	// the IPv6 prefixes of compressed zeros, round trip
+	for _, s := range []string{"1111:0:0:0:4444::/80", "1:0:0:4444::/64", "::/95", "::ffff:0:0/96", "2001:db8::/33", "1:2:3:4:5:6:7:8/126"} {
+		ps, _ := ProcessCIDR(s)
+		if eq, err := EqualPatternTexts([]string{s}, ps); err != nil || !eq {
+			t.Error(s, ps, err)
+		}
+		if added, removed, err := DiffPatternTexts(ps, []string{s}); err != nil || len(added)+len(removed) > 0 {
+			t.Error(s, added, removed, err)
+		}
+	}
+	if _, err := EqualPatternTexts([]string{"x"}, nil); err == nil {
+		t.Error("x")
+	}
+}
+
+func TestDiffSets(t *testing.T) {
+	added, removed, err := DiffSets([]string{"10.0.0.0/23", "192.168.1.1"}, []string{"10.0.1.0/24", "10.0.2.*", "192.168.1.1"})
+	if err != nil || !validate(added, []string{"10.0.2.*"}) || !validate(removed, []string{"10.0.0.*"}) {
+		t.Error(added, removed, err)
+	}
+	added, removed, err = DiffSets([]string{"10.0.0.*"}, []string{"10.0.0.0-10.0.0.255"})
+	if err != nil || len(added) != 0 || len(removed) != 0 {
+		t.Error(added, removed, err)
+	}
+	if _, _, err = DiffSets(nil, []string{"x"}); err == nil {
+		t.Error("x")
+	}
+}
+
+func TestDiffPatternTexts(t *testing.T) {
+	added, removed, err := DiffPatternTexts([]string{"10.0.0.0/23", "192.168.1.1"}, []string{"10.0.1.0/24", "10.0.2.*", "192.168.1.1"})
+	if err != nil || !validate(added, []string{"10.0.2.*"}) || !validate(removed, []string{"10.0.0.*"}) {
+		t.Error(added, removed, err)
+	}
+	added, removed, err = DiffPatternTexts([]string{"2001:db8::/126"}, []string{"2001:db8::1"})
+	if err != nil || len(added) != 0 || strings.Join(removed, " ") != "2001:db8:: 2001:db8::2 2001:db8::3" {
+		t.Error(added, removed, err)
+	}
+	// exactly the IPs spelled with the zero blocks
+	added, removed, err = DiffPatternTexts([]string{"1:0:0:4444::/64"}, []string{"1::4444:*"})
+	if err != nil || len(added) != 0 || strings.Join(removed, " ") != "1:0:0:4444:*" {
+		t.Error(added, removed, err)
+	}
+	if _, _, err = DiffPatternTexts(nil, []string{"x"}); err == nil {
+		t.Error("x")
+	}
+}
+
+func TestVerifyCover(t *testing.T) {
+	tests := []struct {
+		patterns   []string
+		start, end string
+		expected   string
+	}{
+		{[]string{"10.0.0.*", "10.0.1.*"}, "10.0.0.0", "10.0.1.255", ""},
+		{[]string{"10.0.0.0/25", "10.0.0.128-10.0.0.255"}, "10.0.0.0", "10.0.0.255", ""},
+		{[]string{"10.0.0.*", "10.0.2.*"}, "10.0.0.0", "10.0.2.255", "coverage mismatch: gap 10.0.1.0 - 10.0.1.255"},
+		{[]string{"10.0.0.*"}, "10.0.0.1", "10.0.0.255", "coverage mismatch: spillover 10.0.0.0 - 10.0.0.0"},
+		{[]string{"10.0.0.*", "::1"}, "10.0.0.0", "10.0.0.255", "coverage mismatch: spillover ::1 - ::1"},
+		{[]string{"10.0.0.*"}, "10.0.0.255", "10.0.0.0", "reversed range: 10.0.0.255 > 10.0.0.0"},
+	}
+	for _, mt := range tests {
+		err := VerifyCover(mt.patterns, netip.MustParseAddr(mt.start), netip.MustParseAddr(mt.end))
+		if mt.expected == "" && err != nil || mt.expected != "" && (err == nil || err.Error() != mt.expected) {
+			t.Error(mt.patterns, err)
+		}
+	}
+
+	ps, _ := ProcessRange("10.0.0.3", "10.1.2.200")
+	if err := VerifyCover(ps, netip.MustParseAddr("10.0.0.3"), netip.MustParseAddr("10.1.2.200")); err != nil {
+		t.Error(err)
+	}
+	if err := VerifyCover([]string{"x"}, netip.MustParseAddr("10.0.0.3"), netip.MustParseAddr("10.0.0.3")); err == nil {
+		t.Error("x")
+	}
+	if err := VerifyCover([]string{"1111::4444:*"}, netip.MustParseAddr("1111::4444:0:0:0"), netip.MustParseAddr("1111::4444:ffff:ffff:ffff")); !errors.Is(err, ErrAmbiguousPattern) {
+		t.Error(err)
+	}
+}
+
+func TestVerifyPatternTexts(t *testing.T) {
+	tests := []struct {
+		patterns   []string
+		start, end string
+		expected   string
+	}{
+		{[]string{"10.0.0.*", "10.0.1.*"}, "10.0.0.0", "10.0.1.255", ""},
+		{[]string{"10.0.0.0/25", "10.0.0.128-10.0.0.255"}, "10.0.0.0", "10.0.0.255", ""},
+		{[]string{"10.0.0.*", "10.0.2.*"}, "10.0.0.0", "10.0.2.255", "coverage mismatch: gap 10.0.1.*"},
+		{[]string{"10.0.0.*"}, "10.0.0.1", "10.0.0.255", "coverage mismatch: spillover 10.0.0.0"},
+		{[]string{"10.0.0.*", "::1"}, "10.0.0.0", "10.0.0.255", "coverage mismatch: spillover ::1"},
+		{[]string{"1111::4444:*"}, "1111:0:0:0:4444::", "1111:0:0:0:4444:ffff:ffff:ffff", ""},
+		{[]string{"1111:0:0:0:4444::/80"}, "1111:0:0:0:4444::", "1111:0:0:0:4444:ffff:ffff:ffff", ""},
+		{[]string{"1:0:0:4444:*"}, "1:0:0:4444::", "1:0:0:4444:ffff:ffff:ffff:ffff", "coverage mismatch: gap 1::4444:*"},
+		{[]string{"10.0.0.*"}, "10.0.0.255", "10.0.0.0", "reversed range: 10.0.0.255 > 10.0.0.0"},
+	}
+	for _, mt := range tests {
+		err := VerifyPatternTexts(mt.patterns, netip.MustParseAddr(mt.start), netip.MustParseAddr(mt.end))
+		if mt.expected == "" && err != nil || mt.expected != "" && (err == nil || err.Error() != mt.expected) {
+			t.Error(mt.patterns, err)
+		}
+	}
+
+	// the IPv6 prefixes of compressed zeros
+	for _, s := range []string{"1111:0:0:0:4444::/80", "1:0:0:4444::/64", "::/95", "2001:db8::/33", "1:0:0:1::/72"} {
+		p := netip.MustParsePrefix(s)
+		from, to := PrefixToRange(p)
+		ps, _ := ProcessCIDR(s)
+		if err := VerifyPatternTexts(ps, from, to); err != nil {
+			t.Error(s, ps, err)
+		}
+	}
+	if err := VerifyPatternTexts([]string{"x"}, netip.MustParseAddr("10.0.0.3"), netip.MustParseAddr("10.0.0.3")); err == nil {
+		t.Error("x")
+	}
+}
+
+func TestPatternTextRange(t *testing.T) {
+	// 1111::4444:0/112, 1111::4444:0:0/96, 1111::4444:0:0:0/80 and the IPs of
+	// 1111:0:0:4444::/64 not compressing 3 zero blocks of the last 4
+	n := new(big.Int).Lsh(big.NewInt(1), 64)
+	n.Sub(n, big.NewInt(131071))
+	for _, bits := range []uint{48, 32, 16} {
+		n.Add(n, new(big.Int).Lsh(big.NewInt(1), bits))
+	}
+	tests := []struct {
+		s          string
+		start, end string
+		count      *big.Int
+	}{
+		{"10.1.*", "10.1.0.0", "10.1.255.255", big.NewInt(65536)},
+		{"1111::", "1111::", "1111::", big.NewInt(1)},
+		{"1111::4444:*", "1111::4444:0", "1111::4444:ffff:ffff:ffff:ffff", n},
+	}
+	for _, mt := range tests {
+		r, n, err := PatternTextRange(mt.s)
+		if err != nil || r.Start.String() != mt.start || r.End.String() != mt.end || n.Cmp(mt.count) != 0 {
+			t.Error(mt.s, r, n, err)
+		}
+	}
+	if _, _, err := PatternTextRange("x*"); err == nil {
+		t.Error("x*")
+	}
+}
+
+func TestOverlapPatternTexts(t *testing.T) {
+	tests := []struct {
+		a, b     string
+		expected bool
+	}{
+		{"1111::*", "1111::", true},
+		{"1111:0:*", "1111::*", false},
+		{"1111::4444:*", "1111:0:0:4444::/64", true},
+		{"1111::4444:*", "1111:0:0:1::/64", false},
+		{"10.0.0.*", "10.0.0.0/25", true},
+		{"10.0.0.*", "10.0.1.0-10.0.1.9", false},
+	}
+	for _, mt := range tests {
+		if r, err := OverlapPatternTexts(mt.a, mt.b); err != nil || r != mt.expected {
+			t.Error(mt.a, mt.b, r, err)
+		}
+	}
+	if _, err := OverlapPatternTexts("10.*", "x"); err == nil {
+		t.Error("x")
+	}
+}
+
+func TestPatternSetText(t *testing.T) {
+	var config struct {
+		Allow *PatternSet `json:"allow"`
+		Deny  PatternSet  `json:"deny"`
+	}
+	in := `{"allow":"10.0.0.*, 10.0.1.0/24,192.168.0.1-192.168.0.2\n::1","deny":""}`
+	if err := json.Unmarshal([]byte(in), &config); err != nil {
+		t.Fatal(err)
+	}
+	if !config.Allow.Contains(netip.MustParseAddr("10.0.1.1")) || config.Deny.Contains(netip.MustParseAddr("10.0.1.1")) {
+		t.Error(config)
+	}
+	if ps := config.Allow.Prefixes(); len(ps) != 4 || ps[0] != netip.MustParsePrefix("10.0.0.0/23") || len(config.Deny.Prefixes()) != 0 {
+		t.Error(ps)
+	}
+	b, err := json.Marshal(&config)
+	if err != nil || string(b) != `{"allow":"10.0.0.0/23,192.168.0.1/32,192.168.0.2/32,::1/128","deny":""}` {
+		t.Error(string(b), err)
+	}
+
+	var set PatternSet
+	if err = set.UnmarshalText([]byte("10.*,x")); err == nil {
+		t.Error("x")
+	}
+}
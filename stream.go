@@ -0,0 +1,45 @@
+// Copyright 2023-now by lifenjoiner. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE file.
+
+package iprefix
+
+import "context"
+
+// streamBuffer is the count of patterns buffered ahead of the consumer.
+const streamBuffer = 256
+
+// StreamCIDR generates string IP prefix pattern from CIDR in a goroutine,
+// sending them as the caller receives. The parsing error, if any, is sent
+// before both channels are closed. The pattern channel must be drained, or
+// the goroutine leaks; use StreamCIDRContext to stop early.
+func StreamCIDR(s string) (<-chan string, <-chan error) {
+	return StreamCIDRContext(context.Background(), s)
+}
+
+// StreamCIDRContext is StreamCIDR stopping when `ctx` is done, then its error
+// is sent before both channels are closed. The caller can stop receiving
+// after canceling `ctx`.
+func StreamCIDRContext(ctx context.Context, s string) (<-chan string, <-chan error) {
+	ch := make(chan string, streamBuffer)
+	errc := make(chan error, 1)
+	go func() {
+		defer close(errc)
+		defer close(ch)
+		p, err := parsePrefix(s)
+		if err != nil {
+			errc <- err
+			return
+		}
+		processPrefix(p, IPv6All, func(ps string) bool {
+			select {
+			case ch <- ps:
+				return true
+			case <-ctx.Done():
+				errc <- ctx.Err()
+				return false
+			}
+		})
+	}()
+	return ch, errc
+}
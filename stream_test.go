@@ -0,0 +1,63 @@
+// Copyright 2023-now by lifenjoiner. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE file.
+
+package iprefix
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestStreamCIDR(t *testing.T) {
+	prepareExpected()
+	for _, mt := range test {
+		if len(mt.CIDR) == 0 {
+			continue
+		}
+		ch, errc := StreamCIDR(mt.CIDR)
+		var r []string
+		for ps := range ch {
+			r = append(r, ps)
+		}
+		err := <-errc
+		switch d := mt.Expected.(type) {
+		case []string:
+			if err != nil || !validate(r, d) {
+				t.Error(mt.CIDR, err)
+			}
+		case error:
+			if err == nil || len(r) > 0 {
+				t.Error(mt.CIDR)
+			}
+		}
+	}
+}
+
+func TestStreamCIDRContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	ch, errc := StreamCIDRContext(ctx, "::/1")
+	<-ch
+	cancel()
+	// the goroutine ends without the patterns drained
+	if err := <-errc; !errors.Is(err, context.Canceled) {
+		t.Error(err)
+	}
+	n := 0
+	for range ch {
+		n++
+	}
+	if n > streamBuffer {
+		t.Error(n)
+	}
+
+	ch, errc = StreamCIDRContext(context.Background(), "10.0.0.0/23")
+	var r []string
+	for ps := range ch {
+		r = append(r, ps)
+	}
+	if err := <-errc; err != nil || !validate(r, []string{"10.0.0.*", "10.0.1.*"}) {
+		t.Error(r, err)
+	}
+}
@@ -0,0 +1,67 @@
+// Copyright 2023-now by lifenjoiner. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE file.
+
+package iprefix
+
+import (
+	"net/netip"
+	"sync"
+	"sync/atomic"
+)
+
+// SyncPatternSet is a PatternSet safe for concurrent use. Writers copy the
+// current snapshot and swap in the updated one atomically, so readers never
+// block. The zero value is an empty set.
+type SyncPatternSet struct {
+	mu  sync.Mutex
+	set atomic.Pointer[PatternSet]
+}
+
+// Load returns the current snapshot, which must not be modified.
+func (s *SyncPatternSet) Load() *PatternSet {
+	if set := s.set.Load(); set != nil {
+		return set
+	}
+	return &PatternSet{}
+}
+
+// Store replaces the set with a snapshot of `set`.
+func (s *SyncPatternSet) Store(set *PatternSet) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.set.Store(&PatternSet{append([]addrRange(nil), set.ranges...)})
+}
+
+// update swaps in the ranges that `fn` makes of the current ones.
+func (s *SyncPatternSet) update(entry string, fn func(cur []addrRange, r addrRange) []addrRange) error {
+	r, err := parseEntry(entry)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	cur := s.Load().ranges
+	s.set.Store(&PatternSet{fn(cur, r)})
+	return nil
+}
+
+// Add adds a pattern, a CIDR, an IP range `IP1-IP2` or an IP to the set.
+func (s *SyncPatternSet) Add(entry string) error {
+	return s.update(entry, func(cur []addrRange, r addrRange) []addrRange {
+		return mergeRanges(append(append([]addrRange(nil), cur...), r))
+	})
+}
+
+// Remove removes a pattern, a CIDR, an IP range `IP1-IP2` or an IP from the
+// set.
+func (s *SyncPatternSet) Remove(entry string) error {
+	return s.update(entry, func(cur []addrRange, r addrRange) []addrRange {
+		return subtractRanges(cur, []addrRange{r})
+	})
+}
+
+// Contains reports whether `addr` is in the current snapshot.
+func (s *SyncPatternSet) Contains(addr netip.Addr) bool {
+	return s.Load().Contains(addr)
+}
@@ -0,0 +1,58 @@
+// Copyright 2023-now by lifenjoiner. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE file.
+
+package iprefix
+
+import (
+	"fmt"
+	"net/netip"
+	"sync"
+	"testing"
+)
+
+func TestSyncPatternSet(t *testing.T) {
+	var s SyncPatternSet
+	addr := netip.MustParseAddr("10.0.1.1")
+	if s.Contains(addr) {
+		t.Error("empty")
+	}
+	if err := s.Add("10.0.0.0/23"); err != nil || !s.Contains(addr) {
+		t.Error(err)
+	}
+	snapshot := s.Load()
+	if err := s.Remove("10.0.1.0/24"); err != nil || s.Contains(addr) {
+		t.Error(err)
+	}
+	if !snapshot.Contains(addr) {
+		t.Error("snapshot changed")
+	}
+	if err := s.Add("x"); err == nil {
+		t.Error("x")
+	}
+	s.Store(snapshot)
+	if !s.Contains(addr) {
+		t.Error("Store")
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(2)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 32; j++ {
+				s.Add(fmt.Sprintf("10.%d.%d.0/24", i+1, j))
+			}
+		}(i)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				s.Contains(addr)
+			}
+		}()
+	}
+	wg.Wait()
+	if n := s.Load().Count().Int64(); n != 512+8*32*256 {
+		t.Error(n)
+	}
+}
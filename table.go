@@ -0,0 +1,83 @@
+// Copyright 2023-now by lifenjoiner. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE file.
+
+package iprefix
+
+import "net/netip"
+
+// tableNode is a node of a binary trie, branching on the bits of addresses.
+type tableNode[V any] struct {
+	child [2]*tableNode[V]
+	value V
+	// set means the node is an inserted prefix with the value.
+	set bool
+}
+
+// addrBit returns the bit `i` of `ip`, from the most significant one.
+func addrBit(ip []byte, i int) int {
+	return int(ip[i/8]>>(7-i%8)) & 1
+}
+
+// Table maps prefixes to values, looking up an IP by the longest prefix
+// matching it. The zero value is an empty table.
+type Table[V any] struct {
+	v4, v6 tableNode[V]
+}
+
+func (t *Table[V]) root(addr netip.Addr) *tableNode[V] {
+	if addr.Is4() {
+		return &t.v4
+	}
+	return &t.v6
+}
+
+// Insert maps prefix `p` to `v`, replacing the value of the same prefix.
+func (t *Table[V]) Insert(p netip.Prefix, v V) {
+	p = p.Masked()
+	if !p.IsValid() {
+		return
+	}
+	n := t.root(p.Addr())
+	ip := p.Addr().AsSlice()
+	for i := 0; i < p.Bits(); i++ {
+		b := addrBit(ip, i)
+		if n.child[b] == nil {
+			n.child[b] = &tableNode[V]{}
+		}
+		n = n.child[b]
+	}
+	n.value, n.set = v, true
+}
+
+// Add maps a pattern, a CIDR, an IP range `IP1-IP2` or an IP to `v`.
+func (t *Table[V]) Add(s string, v V) error {
+	r, err := parseEntry(s)
+	if err != nil {
+		return err
+	}
+	for _, p := range r.prefixes() {
+		t.Insert(p, v)
+	}
+	return nil
+}
+
+// Lookup returns the value of the longest prefix matching `addr`. IPv4 and
+// 4in6 are different.
+func (t *Table[V]) Lookup(addr netip.Addr) (v V, ok bool) {
+	if !addr.IsValid() {
+		return
+	}
+	n := t.root(addr)
+	ip := addr.AsSlice()
+	for i := 0; n != nil; i++ {
+		if n.set {
+			v, ok = n.value, true
+		}
+		if i == len(ip)*8 {
+			break
+		}
+		n = n.child[addrBit(ip, i)]
+	}
+	return
+}
@@ -0,0 +1,52 @@
+// Copyright 2023-now by lifenjoiner. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE file.
+
+package iprefix
+
+import (
+	"net/netip"
+	"testing"
+)
+
+func TestTable(t *testing.T) {
+	var tb Table[string]
+	tb.Insert(netip.MustParsePrefix("0.0.0.0/0"), "default")
+	tb.Insert(netip.MustParsePrefix("10.0.0.0/8"), "lan")
+	tb.Insert(netip.MustParsePrefix("10.1.2.3/16"), "office")
+	tb.Insert(netip.MustParsePrefix("10.1.2.0/24"), "lab")
+	tb.Insert(netip.MustParsePrefix("2001:db8::/32"), "doc")
+	if err := tb.Add("192.168.0.1-192.168.0.6", "dhcp"); err != nil {
+		t.Error(err)
+	}
+	if err := tb.Add("x", "x"); err == nil {
+		t.Error("x")
+	}
+	tb.Insert(netip.MustParsePrefix("10.0.0.0/8"), "wan")
+
+	tests := []struct {
+		addr     string
+		expected string
+	}{
+		{"1.2.3.4", "default"},
+		{"10.0.0.1", "wan"},
+		{"10.1.0.1", "office"},
+		{"10.1.2.1", "lab"},
+		{"192.168.0.0", "default"},
+		{"192.168.0.1", "dhcp"},
+		{"192.168.0.6", "dhcp"},
+		{"192.168.0.7", "default"},
+		{"2001:db8::1", "doc"},
+		{"2001:db9::1", ""},
+		{"::ffff:10.0.0.1", ""},
+	}
+	for _, mt := range tests {
+		v, ok := tb.Lookup(netip.MustParseAddr(mt.addr))
+		if v != mt.expected || ok != (mt.expected != "") {
+			t.Error(mt.addr, v, ok)
+		}
+	}
+	if _, ok := tb.Lookup(netip.Addr{}); ok {
+		t.Error("zero Addr")
+	}
+}
@@ -0,0 +1,726 @@
+// Copyright 2023-now by lifenjoiner. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE file.
+
+package iprefix
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math/bits"
+	"net/netip"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// A pattern matches the IPs whose canonical text starts with the text before
+// its wildcard. The IPv6 texts compress the longest run of zero blocks, so a
+// pattern spelling `::` or zero blocks may match the IPs of many prefixes, or
+// a part of one. The IPs of the same text shape make a class, where the fields
+// of the text are the digits of a mixed radix number, the rank. The IPs that a
+// pattern matches in a class are then a range of ranks.
+
+// field is a block or an octet of the IPs of a class.
+type field struct {
+	off, width int
+	lo, hi     uint16
+	// text reports whether the field is in the text, or else it is
+	// compressed.
+	text bool
+	dec  bool
+	// sep is the text after the field, "" for the last one.
+	sep string
+}
+
+// textClass is a text shape of the IPs.
+type textClass struct {
+	v4 bool
+	// lead is the text before the first field in the text.
+	lead   string
+	fields []field
+	// weights of the fields in the rank
+	weights []u128
+}
+
+// classes are the IPv4, the 4in6 and the IPv6 classes of the zero blocks
+// bitmap.
+var classes = newClasses()
+
+func newClasses() (cs []*textClass) {
+	v4 := &textClass{v4: true}
+	for i := range 4 {
+		v4.fields = append(v4.fields, field{8 * i, 8, 0, 255, true, true, "."})
+	}
+	v4.fields[3].sep = ""
+	cs = append(cs, v4)
+
+	v4in6 := &textClass{lead: "::"}
+	for i := range 5 {
+		v4in6.fields = append(v4in6.fields, field{off: 16 * i, width: 16})
+	}
+	v4in6.fields = append(v4in6.fields, field{80, 16, 0xffff, 0xffff, true, false, ":"})
+	for i := range 4 {
+		v4in6.fields = append(v4in6.fields, field{96 + 8*i, 8, 0, 255, true, true, "."})
+	}
+	v4in6.fields[9].sep = ""
+	cs = append(cs, v4in6)
+
+	for zeros := range 256 {
+		// the longest zero run, the first one if tied
+		zs, zn := -1, 1
+		for i := 0; i < 8; {
+			j := i
+			for j < 8 && zeros&(1<<j) != 0 {
+				j++
+			}
+			if j-i > zn {
+				zs, zn = i, j-i
+			}
+			i = j + 1
+		}
+		c := &textClass{}
+		if zs == 0 {
+			c.lead = "::"
+		}
+		for i := range 8 {
+			f := field{off: 16 * i, width: 16}
+			switch {
+			case zs >= 0 && i >= zs && i < zs+zn:
+				c.fields = append(c.fields, f)
+				continue
+			case zeros&(1<<i) != 0:
+			case zeros&0x1f == 0x1f && i == 5:
+				// ::ffff:0:0/96 is 4in6
+				f.lo, f.hi = 1, 0xfffe
+			default:
+				f.lo, f.hi = 1, 0xffff
+			}
+			f.text = true
+			switch {
+			case i == 7:
+			case i+1 == zs:
+				f.sep = "::"
+			default:
+				f.sep = ":"
+			}
+			c.fields = append(c.fields, f)
+		}
+		cs = append(cs, c)
+	}
+	for _, c := range cs {
+		c.weights = make([]u128, len(c.fields))
+		w := u128{0, 1}
+		for i := len(c.fields) - 1; i >= 0; i-- {
+			c.weights[i] = w
+			f := c.fields[i]
+			w = w.mul(uint64(f.hi-f.lo) + 1)
+		}
+	}
+	return
+}
+
+// classOf returns the index of the class of an IP.
+func classOf(addr netip.Addr) int {
+	switch {
+	case addr.Is4():
+		return 0
+	case addr.Is4In6():
+		return 1
+	}
+	ip := addr.As16()
+	zeros := 0
+	for i := range 8 {
+		if beUint16(ip[:], i) == 0 {
+			zeros |= 1 << i
+		}
+	}
+	return 2 + zeros
+}
+
+// value returns the value of a field of an IP.
+func (f field) value(ip []byte) uint16 {
+	if f.width == 8 {
+		return uint16(ip[f.off/8])
+	}
+	return beUint16(ip, f.off/16)
+}
+
+// put sets a field of an IP.
+func (f field) put(ip []byte, v uint16) {
+	if f.width == 8 {
+		ip[f.off/8] = byte(v)
+		return
+	}
+	setbeUint16(ip, f.off/16, v)
+}
+
+// ranks returns the range of the ranks of the IPs of the class, whose fields
+// before the n-th are `vs` and the n-th one is from `lo` to `hi`.
+func (c *textClass) ranks(vs []uint16, n int, lo, hi uint16) addrRange {
+	var base u128
+	for i, v := range vs[:n] {
+		base = base.add(c.weights[i].mul(uint64(v - c.fields[i].lo)))
+	}
+	if n == len(c.fields) {
+		return addrRange{base.addr(), base.addr()}
+	}
+	w := c.weights[n]
+	from := base.add(w.mul(uint64(lo - c.fields[n].lo)))
+	to := base.add(w.mul(uint64(hi-c.fields[n].lo) + 1))
+	return addrRange{from.addr(), to.addr().Prev()}
+}
+
+// addrRanks returns the rank of an IP in its class.
+func (c *textClass) addrRanks(addr netip.Addr) addrRange {
+	ip := addr.AsSlice()
+	vs := make([]uint16, len(c.fields))
+	for i, f := range c.fields {
+		vs[i] = f.value(ip)
+	}
+	return c.ranks(vs, len(vs), 0, 0)
+}
+
+// prefixRanks returns the range of the ranks of the IPs of the class in a
+// prefix of the same family.
+func (c *textClass) prefixRanks(p netip.Prefix) (r addrRange, ok bool) {
+	ip := p.Masked().Addr().AsSlice()
+	vs := make([]uint16, len(c.fields))
+	for i, f := range c.fields {
+		v := f.value(ip)
+		switch {
+		case f.off+f.width <= p.Bits():
+			if v < f.lo || v > f.hi {
+				return
+			}
+			vs[i] = v
+		case f.off >= p.Bits():
+			return c.ranks(vs, i, f.lo, f.hi), true
+		default:
+			lo, hi := v, v|(1<<(f.off+f.width-p.Bits())-1)
+			lo, hi = max(lo, f.lo), min(hi, f.hi)
+			if lo > hi {
+				return
+			}
+			return c.ranks(vs, i, lo, hi), true
+		}
+	}
+	return c.ranks(vs, len(vs), 0, 0), true
+}
+
+// token is a field of a head, with the separator after it.
+type token struct {
+	hex, dec     uint16
+	isHex, isDec bool
+	sep          string
+}
+
+// tokenize splits a head, which ends with a separator, into the lead `::` if
+// any and the fields.
+func tokenize(head string) (lead string, ts []token, ok bool) {
+	s := head
+	if strings.HasPrefix(s, "::") {
+		lead, s = "::", s[2:]
+	}
+	for len(s) > 0 {
+		j := strings.IndexAny(s, ":.")
+		if j < 0 {
+			return
+		}
+		var t token
+		t.hex, t.isHex = parseField(s[:j], false)
+		t.dec, t.isDec = parseField(s[:j], true)
+		switch {
+		case s[j] == '.':
+			t.sep = "."
+		case strings.HasPrefix(s[j:], "::"):
+			t.sep = "::"
+		default:
+			t.sep = ":"
+		}
+		ts = append(ts, t)
+		s = s[j+len(t.sep):]
+	}
+	return lead, ts, true
+}
+
+// match parses the fields of the IPs of the class whose text starts with a
+// tokenized head, to the first `n` fixed ones `vs`.
+func (c *textClass) match(lead string, ts []token) (vs []uint16, n int, ok bool) {
+	if lead != c.lead {
+		return
+	}
+	var fixed [10]uint16
+	k := 0
+	n = len(c.fields)
+	for i, f := range c.fields {
+		if !f.text {
+			continue
+		}
+		if k == len(ts) {
+			n = i
+			break
+		}
+		t := ts[k]
+		v, valid := t.hex, t.isHex
+		if f.dec {
+			v, valid = t.dec, t.isDec
+		}
+		if !valid || v < f.lo || v > f.hi {
+			return
+		}
+		// the head may end in the compressed `::`
+		if t.sep != f.sep && !(f.sep == "::" && t.sep == ":" && k == len(ts)-1) {
+			return
+		}
+		fixed[i] = v
+		k++
+	}
+	if k < len(ts) {
+		return
+	}
+	return slices.Clone(fixed[:len(c.fields)]), n, true
+}
+
+// box returns the range of the ranks of the IPs of the class whose first `n`
+// fields are `vs`.
+func (c *textClass) box(vs []uint16, n int) addrRange {
+	if n == len(c.fields) {
+		return c.ranks(vs, n, 0, 0)
+	}
+	return c.ranks(vs, n, c.fields[n].lo, c.fields[n].hi)
+}
+
+// bounds returns the first and the last IP of the class whose first `n`
+// fields are `vs`.
+func (c *textClass) bounds(vs []uint16, n int) addrRange {
+	size := 16
+	if c.v4 {
+		size = 4
+	}
+	from, to := make([]byte, size), make([]byte, size)
+	for i, f := range c.fields {
+		lo, hi := f.lo, f.hi
+		if i < n {
+			lo, hi = vs[i], vs[i]
+		}
+		f.put(from, lo)
+		f.put(to, hi)
+	}
+	addr1, _ := netip.AddrFromSlice(from)
+	addr2, _ := netip.AddrFromSlice(to)
+	return addrRange{addr1, addr2}
+}
+
+// parseField parses a field in its canonical text.
+func parseField(s string, dec bool) (uint16, bool) {
+	base, n := 16, 4
+	if dec {
+		base, n = 10, 3
+	}
+	if len(s) == 0 || len(s) > n || len(s) > 1 && s[0] == '0' {
+		return 0, false
+	}
+	v := 0
+	for i := 0; i < len(s); i++ {
+		d := int(s[i] - '0')
+		switch {
+		case s[i] >= 'a' && s[i] <= 'f':
+			d = int(s[i]-'a') + 10
+		case s[i] < '0' || s[i] > '9':
+			return 0, false
+		}
+		if d >= base {
+			return 0, false
+		}
+		v = v*base + d
+	}
+	if v > 0xffff {
+		return 0, false
+	}
+	return uint16(v), true
+}
+
+// textSet is the set of the IPs of the patterns, as the merged ranges of the
+// ranks of each class.
+type textSet [][]addrRange
+
+func newTextSet() textSet {
+	return make(textSet, len(classes))
+}
+
+// add adds the range of ranks of the i-th class, joining the last one if
+// they are adjacent, as the patterns of the generator are.
+func (ts textSet) add(i int, r addrRange) {
+	if n := len(ts[i]); n > 0 && ts[i][n-1].to.Next() == r.from {
+		ts[i][n-1].to = r.to
+		return
+	}
+	ts[i] = append(ts[i], r)
+}
+
+// addPattern adds the IPs matching a pattern, and returns the range from the
+// first to the last of them. It fails if they are none.
+func (ts textSet) addPattern(s string) (hull addrRange, err error) {
+	if !strings.HasSuffix(s, "*") {
+		var addr netip.Addr
+		if addr, err = parseAddr(s); err != nil {
+			return
+		}
+		i := classOf(addr)
+		ts.add(i, classes[i].addrRanks(addr))
+		return addrRange{addr, addr}, nil
+	}
+	head := s[:len(s)-1]
+	if lead, tokens, ok := tokenize(head); ok && len(head) > 0 {
+		for i, c := range classes {
+			vs, n, ok := c.match(lead, tokens)
+			if !ok {
+				continue
+			}
+			ts.add(i, c.box(vs, n))
+			r := c.bounds(vs, n)
+			if !hull.from.IsValid() || r.from.Less(hull.from) {
+				hull.from = r.from
+			}
+			if !hull.to.IsValid() || hull.to.Less(r.to) {
+				hull.to = r.to
+			}
+		}
+	}
+	if !hull.from.IsValid() {
+		err = fmt.Errorf("%w: %q matches no IP", ErrInvalidPattern, s)
+	}
+	return
+}
+
+// addPrefix adds the IPs of a prefix.
+func (ts textSet) addPrefix(p netip.Prefix) {
+	for i, c := range classes {
+		if c.v4 != p.Addr().Is4() {
+			continue
+		}
+		if r, ok := c.prefixRanks(p); ok {
+			ts.add(i, r)
+		}
+	}
+}
+
+// addRanges adds the IPs of merged ranges.
+func (ts textSet) addRanges(rs []addrRange) {
+	for _, p := range rangesPrefixes(rs) {
+		ts.addPrefix(p)
+	}
+}
+
+func (ts textSet) merge() textSet {
+	for i, rs := range ts {
+		ts[i] = mergeRanges(rs)
+	}
+	return ts
+}
+
+func (ts textSet) equal(other textSet) bool {
+	for i := range ts {
+		if !slices.Equal(ts[i], other[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// subtract returns the IPs of merged `ts` not in merged `other`.
+func (ts textSet) subtract(other textSet) textSet {
+	x := newTextSet()
+	for i := range ts {
+		x[i] = subtractRanges(ts[i], other[i])
+	}
+	return x
+}
+
+// intersects reports whether merged `ts` and `other` overlap.
+func (ts textSet) intersects(other textSet) bool {
+	for i := range ts {
+		if len(intersectRanges(ts[i], other[i])) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func (ts textSet) empty() bool {
+	for _, rs := range ts {
+		if len(rs) > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// addEntry adds the IPs matching a pattern, or the patterns generated from a
+// CIDR, an IP range `IP1-IP2` or an IP.
+func (ts textSet) addEntry(s string) error {
+	if strings.HasSuffix(s, "*") {
+		_, err := ts.addPattern(s)
+		return err
+	}
+	r, err := parseEntry(s)
+	if err != nil {
+		return err
+	}
+	processRange(r.from, r.to, IPv6All, func(p string) bool {
+		ts.addPattern(p)
+		return true
+	})
+	return nil
+}
+
+// entriesTextSet returns the merged textSet of patterns, CIDRs, IP ranges
+// `IP1-IP2` or IPs, see addEntry.
+func entriesTextSet(ss []string) (textSet, error) {
+	ts := newTextSet()
+	for i, s := range ss {
+		if err := ts.addEntry(s); err != nil {
+			return nil, &EntryError{s, i + 1, err}
+		}
+	}
+	return ts.merge(), nil
+}
+
+// state is the IPs of a class whose text starts with a head: the first `n`
+// fields are `vs`, and `pend` is the text left of the separator before the
+// n-th field.
+type state struct {
+	c    int
+	vs   []uint16
+	n    int
+	pend string
+}
+
+// next returns the index of the first field in the text from the i-th one.
+func (c *textClass) next(i int) int {
+	for i < len(c.fields) && !c.fields[i].text {
+		i++
+	}
+	return i
+}
+
+// valueAt returns the value of the n-th field of the IPs of the rank in the
+// box of the first `n` fields `vs`.
+func (c *textClass) valueAt(vs []uint16, n int, rank netip.Addr) uint16 {
+	f := c.fields[n]
+	lo, hi := int(f.lo), int(f.hi)
+	for lo < hi {
+		mid := (lo + hi + 1) / 2
+		if rank.Less(c.ranks(vs, n, uint16(mid), uint16(mid)).from) {
+			hi = mid - 1
+		} else {
+			lo = mid
+		}
+	}
+	return uint16(lo)
+}
+
+// cover returns 1 if merged `rs` cover all of `r`, 0 if none of it, or else
+// -1.
+func cover(rs []addrRange, r addrRange) int {
+	i := sort.Search(len(rs), func(i int) bool {
+		return !rs[i].to.Less(r.from)
+	})
+	switch {
+	case i == len(rs) || r.to.Less(rs[i].from):
+		return 0
+	case !r.from.Less(rs[i].from) && !rs[i].to.Less(r.to):
+		return 1
+	}
+	return -1
+}
+
+// patterns generates the patterns matching exactly the IPs of merged `ts`,
+// the broadest heads first.
+func (ts textSet) patterns(yield func(string) bool) bool {
+	var root []state
+	for i, c := range classes {
+		root = append(root, state{i, make([]uint16, len(c.fields)), c.next(0), c.lead})
+	}
+	return ts.headPatterns("", root, yield)
+}
+
+// headPatterns generates the patterns of the IPs of merged `ts` whose text
+// starts with the head `h`, of the states `ss`.
+func (ts textSet) headPatterns(h string, ss []state, yield func(string) bool) bool {
+	in, out := true, true
+	for _, st := range ss {
+		switch cover(ts[st.c], classes[st.c].box(st.vs, st.n)) {
+		case 0:
+			in = false
+		case 1:
+			out = false
+		default:
+			in, out = false, false
+		}
+	}
+	switch {
+	case out:
+		return true
+	case in && len(h) > 0 && h != ":":
+		// the lead ":" of "::" is no head
+		return yield(h + "*")
+	}
+
+	// the IPs of the head text, the rest of their separators and their next
+	// fields, by the separator after them
+	type group struct {
+		dec bool
+		sep string
+		ss  []state
+	}
+	var pends, values []group
+	for _, st := range ss {
+		c := classes[st.c]
+		switch {
+		case len(st.pend) > 0:
+			i := slices.IndexFunc(pends, func(g group) bool { return g.sep == st.pend[:1] })
+			if i < 0 {
+				i = len(pends)
+				pends = append(pends, group{sep: st.pend[:1]})
+			}
+			pends[i].ss = append(pends[i].ss, state{st.c, st.vs, st.n, st.pend[1:]})
+		case st.n == len(c.fields):
+			if cover(ts[st.c], c.box(st.vs, st.n)) == 1 && !yield(h) {
+				return false
+			}
+		default:
+			f := c.fields[st.n]
+			sep := f.sep[:min(1, len(f.sep))]
+			i := slices.IndexFunc(values, func(g group) bool { return g.dec == f.dec && g.sep == sep })
+			if i < 0 {
+				i = len(values)
+				values = append(values, group{dec: f.dec, sep: sep})
+			}
+			values[i].ss = append(values[i].ss, st)
+		}
+	}
+	for _, g := range pends {
+		if !ts.headPatterns(h+g.sep, g.ss, yield) {
+			return false
+		}
+	}
+	for _, g := range values {
+		if !ts.valuePatterns(h, g.dec, g.sep, g.ss, yield) {
+			return false
+		}
+	}
+	return true
+}
+
+// valuePatterns generates the patterns of the IPs of merged `ts` whose text
+// starts with the head `h` and the next field of the states `ss`, followed by
+// `sep`, or ending if it is empty.
+func (ts textSet) valuePatterns(h string, dec bool, sep string, ss []state, yield func(string) bool) bool {
+	// the values between the breaks are alike
+	var breaks []int
+	for _, st := range ss {
+		c := classes[st.c]
+		f := c.fields[st.n]
+		breaks = append(breaks, int(f.lo), int(f.hi)+1)
+		box := c.box(st.vs, st.n)
+		rs := ts[st.c]
+		i := sort.Search(len(rs), func(i int) bool {
+			return !rs[i].to.Less(box.from)
+		})
+		for ; i < len(rs) && !box.to.Less(rs[i].from); i++ {
+			for _, rank := range []netip.Addr{rs[i].from, rs[i].to} {
+				if !rank.Less(box.from) && !box.to.Less(rank) {
+					v := int(c.valueAt(st.vs, st.n, rank))
+					breaks = append(breaks, v, v+1)
+				}
+			}
+		}
+	}
+	slices.Sort(breaks)
+	breaks = slices.Compact(breaks)
+	text := func(v uint16) string {
+		if dec {
+			return h + strconv.Itoa(int(v)) + sep
+		}
+		return h + strconv.FormatUint(uint64(v), 16) + sep
+	}
+	for k := 0; k+1 < len(breaks); k++ {
+		lo, hi := uint16(breaks[k]), uint16(breaks[k+1]-1)
+		in, out := true, true
+		var run []state
+		for _, st := range ss {
+			f := classes[st.c].fields[st.n]
+			if lo < f.lo || lo > f.hi {
+				continue
+			}
+			run = append(run, st)
+			switch cover(ts[st.c], classes[st.c].ranks(st.vs, st.n, lo, hi)) {
+			case 0:
+				in = false
+			case 1:
+				out = false
+			default:
+				in, out = false, false
+			}
+		}
+		if len(run) == 0 || out {
+			continue
+		}
+		for v := int(lo); v <= int(hi); v++ {
+			switch {
+			case in && len(sep) > 0:
+				if !yield(text(uint16(v)) + "*") {
+					return false
+				}
+			case len(sep) == 0:
+				// the IPs ending with the field
+				for _, st := range run {
+					r := classes[st.c].ranks(st.vs, st.n, uint16(v), uint16(v))
+					if cover(ts[st.c], r) == 1 && !yield(text(uint16(v))) {
+						return false
+					}
+				}
+			default:
+				next := make([]state, len(run))
+				for i, st := range run {
+					c := classes[st.c]
+					vs := slices.Clone(st.vs)
+					vs[st.n] = uint16(v)
+					f := c.fields[st.n]
+					next[i] = state{st.c, vs, c.next(st.n + 1), f.sep[min(1, len(f.sep)):]}
+				}
+				if !ts.headPatterns(text(uint16(v)), next, yield) {
+					return false
+				}
+			}
+		}
+	}
+	return true
+}
+
+// u128 is an unsigned 128-bit number, the ranks carried in IPv6 addresses to
+// use the range helpers.
+type u128 struct {
+	hi, lo uint64
+}
+
+func (a u128) add(b u128) u128 {
+	lo, carry := bits.Add64(a.lo, b.lo, 0)
+	hi, _ := bits.Add64(a.hi, b.hi, carry)
+	return u128{hi, lo}
+}
+
+func (a u128) mul(n uint64) u128 {
+	hi, lo := bits.Mul64(a.lo, n)
+	return u128{a.hi*n + hi, lo}
+}
+
+func (a u128) addr() netip.Addr {
+	var b [16]byte
+	binary.BigEndian.PutUint64(b[:8], a.hi)
+	binary.BigEndian.PutUint64(b[8:], a.lo)
+	return netip.AddrFrom16(b)
+}
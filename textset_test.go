@@ -0,0 +1,170 @@
+// Copyright 2023-now by lifenjoiner. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE file.
+
+package iprefix
+
+import (
+	"math/rand"
+	"net/netip"
+	"strings"
+	"testing"
+)
+
+// randTextAddr returns a random IP of few and small blocks, so the texts
+// share heads.
+func randTextAddr(rnd *rand.Rand) netip.Addr {
+	var ip [16]byte
+	switch rnd.Intn(4) {
+	case 0:
+		for i := 12; i < 16; i++ {
+			ip[i] = byte(rnd.Intn(3))
+		}
+		return netip.AddrFrom4([4]byte(ip[12:]))
+	case 1:
+		ip[10], ip[11] = 0xff, 0xff
+		for i := 12; i < 16; i++ {
+			ip[i] = byte(rnd.Intn(3))
+		}
+		return netip.AddrFrom16(ip)
+	}
+	for i := range 8 {
+		switch rnd.Intn(4) {
+		case 0:
+			setbeUint16(ip[:], i, uint16(rnd.Intn(2)+1))
+		case 1:
+			setbeUint16(ip[:], i, 0xffff)
+		}
+	}
+	return netip.AddrFrom16(ip)
+}
+
+func (ts textSet) contains(addr netip.Addr) bool {
+	i := classOf(addr)
+	return searchRanges(ts[i], classes[i].addrRanks(addr).from) >= 0
+}
+
+func TestTextSet(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	addrs := make([]netip.Addr, 1000)
+	for i := range addrs {
+		addrs[i] = randTextAddr(rnd)
+	}
+	for range 100 {
+		s := randTextAddr(rnd).String()
+		var heads []string
+		for i := 1; i < len(s); i++ {
+			if (s[i] == ':' || s[i] == '.') && s[:i+1] != ":" {
+				heads = append(heads, s[:i+1])
+			}
+		}
+		for _, head := range heads {
+			ts := newTextSet()
+			hull, err := ts.addPattern(head + "*")
+			if err != nil {
+				t.Fatal(head, err)
+			}
+			ts.merge()
+			for _, addr := range addrs {
+				in := strings.HasPrefix(addr.String(), head)
+				if ts.contains(addr) != in || in && (addr.Less(hull.from) || hull.to.Less(addr)) {
+					t.Fatal(head, addr, hull)
+				}
+			}
+		}
+
+		addr := addrs[rnd.Intn(len(addrs))]
+		p := netip.PrefixFrom(addr, rnd.Intn(addr.BitLen()+1)).Masked()
+		ts := newTextSet()
+		ts.addPrefix(p)
+		ts.merge()
+		for _, addr := range addrs {
+			if ts.contains(addr) != p.Contains(addr) {
+				t.Fatal(p, addr)
+			}
+		}
+	}
+
+	for _, s := range []string{"1:0:0:0:*", "2001:DB8:*", "::1.*", "1:*:", "*"} {
+		if _, err := newTextSet().addPattern(s); err == nil {
+			t.Error(s)
+		}
+	}
+}
+
+// randHead returns a random head, not of the broadest ones so the patterns
+// are few, of the text of a random IP, or the text.
+func randHead(rnd *rand.Rand) string {
+	s := randTextAddr(rnd).String()
+	var heads []string
+	for i := 1; i < len(s); i++ {
+		if (s[i] == ':' || s[i] == '.') && s[:i+1] != ":" {
+			heads = append(heads, s[:i+1]+"*")
+		}
+	}
+	heads = append(heads, s)
+	return heads[len(heads)/2+rnd.Intn(len(heads)-len(heads)/2)]
+}
+
+func TestTextSetPatterns(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	addrs := make([]netip.Addr, 1000)
+	for i := range addrs {
+		addrs[i] = randTextAddr(rnd)
+	}
+	for range 300 {
+		var a, b []string
+		for range rnd.Intn(4) {
+			a = append(a, randHead(rnd))
+		}
+		for range rnd.Intn(4) + 1 {
+			b = append(b, randHead(rnd))
+		}
+		if rnd.Intn(2) == 0 {
+			// few patterns of a prefix
+			addr := randTextAddr(rnd)
+			w := 8
+			if addr.Is6() {
+				w = 16
+			}
+			bits := (rnd.Intn(addr.BitLen()/w)+1)*w - rnd.Intn(3)
+			a = append(a, netip.PrefixFrom(addr, bits).Masked().String())
+		}
+		x, err := entriesTextSet(a)
+		if err != nil {
+			t.Fatal(a, err)
+		}
+		y, err := entriesTextSet(b)
+		if err != nil {
+			t.Fatal(b, err)
+		}
+		d := y.subtract(x)
+		ps := map[string]bool{}
+		d.patterns(func(p string) bool {
+			ps[p] = true
+			return true
+		})
+		for _, addr := range addrs {
+			s := addr.String()
+			in := ps[s]
+			for i := range len(s) + 1 {
+				in = in || ps[s[:i]+"*"]
+			}
+			if in != d.contains(addr) {
+				t.Fatal(a, b, addr)
+			}
+		}
+		if len(ps) > 1000 {
+			continue
+		}
+		z := newTextSet()
+		for p := range ps {
+			if _, err = z.addPattern(p); err != nil {
+				t.Fatal(a, b, err)
+			}
+		}
+		if !z.merge().equal(d) {
+			t.Fatal(a, b, len(ps))
+		}
+	}
+}
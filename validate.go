@@ -0,0 +1,26 @@
+// Copyright 2023-now by lifenjoiner. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE file.
+
+package iprefix
+
+// ValidateCIDR runs the checks of ProcessCIDR without generating the patterns.
+func ValidateCIDR(s string) error {
+	_, err := parsePrefix(s)
+	return err
+}
+
+// ValidateRange runs the checks of ProcessRange without generating the
+// patterns: the IPs, the families and the order.
+// `s` is start IP. `e` is end IP.
+func ValidateRange(s, e string) error {
+	_, _, err := parseRange(s, e)
+	return err
+}
+
+// Validate runs the checks of Process on an entry of any syntax without
+// generating the patterns, for linting the inputs quickly.
+func Validate(s string) error {
+	_, err := newOptions(nil).generator(s)
+	return err
+}
@@ -0,0 +1,62 @@
+// Copyright 2023-now by lifenjoiner. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE file.
+
+package iprefix
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		s        string
+		expected error
+	}{
+		{"10.0.0.0/8", nil},
+		{"::/0", nil},
+		{"10.0.0.0 0.0.1.255", nil},
+		{"10.0.0.1-10.0.0.9", nil},
+		{"::1", nil},
+		{"10.0.0.0/33", ErrInvalidCIDR},
+		{"10.0.0.x", ErrInvalidIP},
+		{"10.0.0.1-::1", ErrMixedFamilies},
+		{"10.0.0.2-10.0.0.1", ErrReversedRange},
+	}
+	for _, mt := range tests {
+		if err := Validate(mt.s); !errors.Is(err, mt.expected) {
+			t.Error(mt.s, err)
+		}
+	}
+
+	cidrs := []struct {
+		s        string
+		expected error
+	}{
+		{"10.0.0.0/8", nil},
+		{"2001:db8::/129", ErrInvalidCIDR},
+		{"10.0.0.0", ErrInvalidCIDR},
+	}
+	for _, mt := range cidrs {
+		if err := ValidateCIDR(mt.s); !errors.Is(err, mt.expected) {
+			t.Error(mt.s, err)
+		}
+	}
+
+	ranges := []struct {
+		s, e     string
+		expected error
+	}{
+		{"10.0.0.1", "10.0.0.1", nil},
+		{"::1", "::2", nil},
+		{"x", "10.0.0.1", ErrInvalidIP},
+		{"10.0.0.1", "::1", ErrMixedFamilies},
+		{"::2", "::1", ErrReversedRange},
+	}
+	for _, mt := range ranges {
+		if err := ValidateRange(mt.s, mt.e); !errors.Is(err, mt.expected) {
+			t.Error(mt.s, mt.e, err)
+		}
+	}
+}